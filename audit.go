@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// AuditEntry records a single write to a motor. Payload holds the motor as
+// it stood after the change (or, for deletes, when it was deleted).
+type AuditEntry struct {
+	ID       int64  `json:"id"`
+	Action   string `json:"action"`
+	SerialNo string `json:"serial_no"`
+	// MotorID tells apart motors that shared a serial, as one can be
+	// registered again after its motor is deleted. It's 0 for legacy
+	// entries whose motor is gone.
+	MotorID   int64           `json:"motor_id"`
+	Payload   json.RawMessage `json:"payload"`
+	Actor     string          `json:"actor"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+const auditColumns = `id, action, serial_no, motor_id, payload, actor, created_at`
+
+func scanAudit(row rowScanner) (AuditEntry, error) {
+	var e AuditEntry
+	var motorID sql.NullInt64
+	err := row.Scan(&e.ID, &e.Action, &e.SerialNo, &motorID, &e.Payload, &e.Actor, &e.CreatedAt)
+	e.MotorID = motorID.Int64
+	return e, err
+}
+
+// auditActor names who made the request, from the JWT subject
+func auditActor(ctx context.Context) string {
+	if sub, ok := claimsFrom(ctx)["sub"].(string); ok && sub != "" {
+		return sub
+	}
+	return "anonymous"
+}
+
+// writeAudit logs a write to motor. It should be given the transaction that
+// made the change so the two commit or roll back together.
+func writeAudit(ctx context.Context, tx *sql.Tx, action string, motor Motor) error {
+	payload, err := json.Marshal(motor)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO audit_log (action, serial_no, motor_id, payload, actor) VALUES ($1, $2, $3, $4, $5)",
+		action, motor.SerialNo, motor.ID, payload, auditActor(ctx))
+	return err
+}
+
+// auditSerialMatch matches audit entries for serial number $1, including
+// those of motors stored before serials were normalized
+const auditSerialMatch = "(serial_no = $1 OR upper(trim(serial_no)) = $1)"
+
+// listAudit returns a motor's audit trail, oldest first
+func listAudit(w http.ResponseWriter, r *http.Request) {
+	serial := normalizeSerial(r.URL.Query().Get("serial_no"))
+	if serial == "" {
+		writeJSONError(w, http.StatusBadRequest, "serial_no is required")
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT "+auditColumns+" FROM audit_log WHERE "+auditSerialMatch+" ORDER BY created_at, id", serial)
+	if err != nil {
+		dbError(w, r, "Error fetching audit log: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching audit log: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Motor fields every write changes, left out of history diffs as noise
+var historyIgnoredFields = map[string]bool{"updated_at": true, "version": true}
+
+// fieldChange is one field's value before and after a write
+type fieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// historyEntry is an audit entry with what it changed since the one before
+type historyEntry struct {
+	ID        int64                  `json:"id"`
+	Action    string                 `json:"action"`
+	Actor     string                 `json:"actor"`
+	CreatedAt time.Time              `json:"created_at"`
+	Changes   map[string]fieldChange `json:"changes"`
+	Motor     json.RawMessage        `json:"motor"`
+}
+
+// diffPayloads lists the fields that differ between two audited motors. A
+// nil before, for the first entry, counts every field set in after as
+// changed from null.
+func diffPayloads(before, after json.RawMessage) (map[string]fieldChange, error) {
+	var from, to map[string]interface{}
+	if before != nil {
+		if err := json.Unmarshal(before, &from); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(after, &to); err != nil {
+		return nil, err
+	}
+
+	changes := map[string]fieldChange{}
+	for key, v := range to {
+		if !historyIgnoredFields[key] && !reflect.DeepEqual(from[key], v) {
+			changes[key] = fieldChange{From: from[key], To: v}
+		}
+	}
+	for key, v := range from {
+		if _, ok := to[key]; !ok && !historyIgnoredFields[key] {
+			changes[key] = fieldChange{From: v}
+		}
+	}
+	return changes, nil
+}
+
+// motorHistory returns the live motor's audit trail oldest first, each entry
+// with the fields it changed. Entries of earlier, deleted motors with the
+// same serial are left out.
+func motorHistory(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	motor, err := findMotor(r.Context(), db, serial)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT "+auditColumns+" FROM audit_log WHERE motor_id = $1 ORDER BY created_at, id", motor.ID)
+	if err != nil {
+		dbError(w, r, "Error fetching audit log: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	history := []historyEntry{}
+	var previous json.RawMessage
+	for rows.Next() {
+		entry, err := scanAudit(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		changes, err := diffPayloads(previous, entry.Payload)
+		if err != nil {
+			dbError(w, r, "Error reading audit payload: "+err.Error())
+			return
+		}
+		history = append(history, historyEntry{
+			ID: entry.ID, Action: entry.Action, Actor: entry.Actor, CreatedAt: entry.CreatedAt,
+			Changes: changes, Motor: entry.Payload,
+		})
+		previous = entry.Payload
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching audit log: "+err.Error())
+		return
+	}
+	if len(history) == 0 {
+		writeJSONError(w, http.StatusNotFound, "No history for that motor")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"serial_no": serial, "data": history})
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiffPayloads(t *testing.T) {
+	registered := json.RawMessage(`{"serial_no": "SN1", "rpm": 1440, "remarks": "", "version": 1, "updated_at": "2024-01-01T00:00:00Z"}`)
+	updated := json.RawMessage(`{"serial_no": "SN1", "rpm": 2880, "remarks": "rewound", "version": 2, "updated_at": "2024-02-01T00:00:00Z"}`)
+
+	got, err := diffPayloads(registered, updated)
+	if err != nil {
+		t.Fatalf("diffPayloads: %v", err)
+	}
+	want := map[string]fieldChange{
+		"rpm":     {From: 1440.0, To: 2880.0},
+		"remarks": {From: "", To: "rewound"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %v, want %v", got, want)
+	}
+
+	first, err := diffPayloads(nil, registered)
+	if err != nil {
+		t.Fatalf("diffPayloads(nil, ...): %v", err)
+	}
+	if len(first) != 3 || first["serial_no"] != (fieldChange{To: "SN1"}) {
+		t.Errorf("first entry changes = %v, want every field but version and updated_at from null", first)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const claimsKey contextKey = "claims"
+
+// claimsFrom returns the JWT claims attached by requireAuth, or nil when the
+// request wasn't authenticated
+func claimsFrom(ctx context.Context) jwt.MapClaims {
+	claims, _ := ctx.Value(claimsKey).(jwt.MapClaims)
+	return claims
+}
+
+// parseBearer validates the request's HS256 Bearer token against JWT_SECRET
+// and returns its claims. With no secret configured every token is rejected.
+func parseBearer(r *http.Request) (jwt.MapClaims, error) {
+	secret := []byte(os.Getenv("JWT_SECRET"))
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || len(secret) == 0 {
+		return nil, errors.New("missing bearer token")
+	}
+
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(raw), claims, func(t *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, jwt.WithValidMethods([]string{"HS256"}))
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// isAdmin reports whether the request carries a valid token with the admin
+// role. It works on routes that don't go through requireAuth.
+func isAdmin(r *http.Request) bool {
+	claims, err := parseBearer(r)
+	return err == nil && claims["role"] == "admin"
+}
+
+// requireAuth only lets requests through that carry a valid Bearer token,
+// attaching its claims to the request context
+func requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := parseBearer(r)
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey, claims)))
+	})
+}
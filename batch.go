@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Most serial numbers one batch fetch may ask for
+const maxBatchSerials = 500
+
+// fetchBatch returns the motors for a list of serial numbers, such as a
+// scanned batch, in one lookup. Motors come back in the order asked for,
+// and serials with no live motor are listed in not_found.
+func (s *server) fetchBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req struct {
+		SerialNos []string `json:"serial_nos"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&req)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	serials := make([]string, 0, len(req.SerialNos))
+	seen := map[string]bool{}
+	for _, serial := range req.SerialNos {
+		if serial = normalizeSerial(serial); serial != "" && !seen[serial] {
+			seen[serial] = true
+			serials = append(serials, serial)
+		}
+	}
+	if len(serials) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "serial_nos must list at least one serial number")
+		return
+	}
+	if len(serials) > maxBatchSerials {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("At most %d serial numbers may be fetched at once", maxBatchSerials))
+		return
+	}
+
+	found, err := s.store.GetBySerials(r.Context(), serials)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	bySerial := make(map[string]Motor, len(found))
+	for _, motor := range found {
+		bySerial[normalizeSerial(motor.SerialNo)] = motor
+	}
+
+	motors := make([]map[string]interface{}, 0, len(found))
+	notFound := []string{}
+	for _, serial := range serials {
+		if motor, ok := bySerial[serial]; ok {
+			motors = append(motors, motorResponse(motor))
+		} else {
+			notFound = append(notFound, serial)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":      motors,
+		"not_found": notFound,
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Fields a bulk update may set. They describe the party or the shipment
+// rather than the motor itself, so one value can be right for many motors.
+var bulkUpdatableFields = map[string]bool{
+	"party_name":       true,
+	"party_address":    true,
+	"party_email":      true,
+	"transport_agency": true,
+	"remarks":          true,
+}
+
+// buildBulkUpdate returns the UPDATE setting values, keyed by JSON field
+// name, on the live motors matching filters. Motors already holding every
+// value are left alone, so only rows that really change are returned.
+func buildBulkUpdate(filters map[string]interface{}, values map[string]string) (string, []interface{}) {
+	filters["deleted_at IS NULL"] = nil
+	where, args := buildWhere(filters)
+
+	fields := make([]string, 0, len(values))
+	for key := range values {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+
+	sets := make([]string, 0, len(fields)+2)
+	same := make([]string, 0, len(fields))
+	for _, key := range fields {
+		column := patchableFields[key].column
+		args = append(args, values[key])
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+		same = append(same, fmt.Sprintf("%s IS NOT DISTINCT FROM $%d", column, len(args)))
+	}
+	sets = append(sets, "updated_at = now()", "version = version + 1")
+	return "UPDATE motors SET " + strings.Join(sets, ", ") + " " + where +
+		" AND NOT (" + strings.Join(same, " AND ") + ") RETURNING " + motorSelectColumns, args
+}
+
+// bulkUpdateMotors sets the fields in the request body, e.g.
+// {"party_address": "..."}, on every live motor matching the fetch filters
+// in the query string, in one transaction. It refuses to run without a
+// filter so a mistake can't rewrite every motor.
+func bulkUpdateMotors(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseMotorFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if filter.isEmpty() {
+		writeJSONError(w, http.StatusBadRequest, "At least one filter is required for a bulk update")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var values map[string]string
+	err = json.NewDecoder(r.Body).Decode(&values)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input; expected an object of field names to string values")
+		return
+	}
+	if len(values) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+	fields := make([]string, 0, len(values))
+	for key := range values {
+		fields = append(fields, key)
+	}
+	sort.Strings(fields)
+	for _, key := range fields {
+		if !bulkUpdatableFields[key] {
+			writeJSONError(w, http.StatusBadRequest, key+" can't be bulk updated")
+			return
+		}
+		values[key] = strings.TrimSpace(values[key])
+	}
+	if name, ok := values["party_name"]; ok && name == "" {
+		writeJSONError(w, http.StatusBadRequest, "party_name can't be empty")
+		return
+	}
+	if email := values["party_email"]; email != "" && !isBareEmail(email) {
+		writeJSONError(w, http.StatusBadRequest, "party_email must be a valid email address")
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+	defer tx.Rollback()
+
+	query, args := buildBulkUpdate(filter.conditions(), values)
+	rows, err := tx.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+	var updated []Motor
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			rows.Close()
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		updated = append(updated, motor)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+
+	// The rows have to be read off before the transaction can run the
+	// audit inserts
+	for _, motor := range updated {
+		if err := writeAudit(r.Context(), tx, "update", motor); err != nil {
+			dbError(w, r, "Error updating data")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"updated": len(updated)})
+}
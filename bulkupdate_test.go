@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildBulkUpdate(t *testing.T) {
+	query, args := buildBulkUpdate(map[string]interface{}{"party_name = $%d": "Acme"},
+		map[string]string{"party_address": "12 New Rd", "party_email": "ops@acme.test"})
+
+	want := "UPDATE motors SET party_address = $2, party_email = $3, updated_at = now(), version = version + 1 " +
+		"WHERE deleted_at IS NULL AND party_name = $1 " +
+		"AND NOT (party_address IS NOT DISTINCT FROM $2 AND party_email IS NOT DISTINCT FROM $3) RETURNING " + motorSelectColumns
+	if query != want {
+		t.Errorf("query =\n%s\nwant\n%s", query, want)
+	}
+	if wantArgs := []interface{}{"Acme", "12 New Rd", "ops@acme.test"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestBulkUpdateMotorsRejects(t *testing.T) {
+	tests := []struct {
+		name, query, body string
+		want              int
+	}{
+		{"no filter", "", `{"party_address": "x"}`, http.StatusBadRequest},
+		{"blank filter", "?party_name=%20", `{"party_address": "x"}`, http.StatusBadRequest},
+		{"no fields", "?party_name=Acme", `{}`, http.StatusBadRequest},
+		{"field not allowed", "?party_name=Acme", `{"phase": "three"}`, http.StatusBadRequest},
+		{"non-string value", "?party_name=Acme", `{"remarks": 5}`, http.StatusBadRequest},
+		{"empty party name", "?party_name=Acme", `{"party_name": " "}`, http.StatusBadRequest},
+		{"bad email", "?party_name=Acme", `{"party_email": "Ops <ops@acme.test>"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			bulkUpdateMotors(rec, httptest.NewRequest("PUT", "/motors/bulk-update"+tt.query, strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
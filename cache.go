@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// How long cached aggregates are served when AGGREGATE_CACHE_TTL isn't set
+const defaultAggregateCacheTTL = 60 * time.Second
+
+// Most responses the cache holds at once. /parties is keyed by its q
+// prefix, so typeahead could otherwise fill it without bound.
+const maxCacheEntries = 1000
+
+var cacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cache_requests_total",
+	Help: "Aggregate cache lookups, by cache and result (hit or miss).",
+}, []string{"cache", "result"})
+
+type cachedResponse struct {
+	body    []byte
+	expires time.Time
+}
+
+// responseCache keeps successful responses of read-heavy aggregate
+// endpoints for a while. Any motor write clears it, so the TTL only bounds
+// staleness from things it can't see, like warranties expiring overnight.
+type responseCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+	// gen counts clears, so a response computed before a write isn't
+	// stored after it
+	gen int
+}
+
+// newResponseCache returns a cache keeping responses for ttl, or nil, which
+// caches nothing, when ttl isn't positive
+func newResponseCache(ttl time.Duration) *responseCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &responseCache{ttl: ttl, entries: map[string]cachedResponse{}}
+}
+
+// cached serves next's 200 responses from the cache, keyed by name and the
+// request's query string
+func (c *responseCache) cached(name string, next http.HandlerFunc) http.HandlerFunc {
+	if c == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Encode sorts the parameters, so their order doesn't split entries
+		key := name + "?" + r.URL.Query().Encode()
+		body, gen, ok := c.get(key)
+		if ok {
+			cacheRequestsTotal.WithLabelValues(name, "hit").Inc()
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Cache", "HIT")
+			w.Write(body)
+			return
+		}
+		cacheRequestsTotal.WithLabelValues(name, "miss").Inc()
+
+		w.Header().Set("X-Cache", "MISS")
+		rec := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		if rec.status == http.StatusOK {
+			c.put(key, gen, rec.body.Bytes())
+		}
+	}
+}
+
+// invalidating clears the cache after each successful request to next, for
+// wrapping the routes that change motors
+func (c *responseCache) invalidating(next http.Handler) http.Handler {
+	if c == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		if rec.status < 400 {
+			c.clear()
+		}
+	})
+}
+
+func (c *responseCache) get(key string) ([]byte, int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok || time.Now().After(e.expires) {
+		return nil, c.gen, false
+	}
+	return e.body, c.gen, true
+}
+
+// put stores body under key unless the cache was cleared since gen
+func (c *responseCache) put(key string, gen int, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	now := time.Now()
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = cachedResponse{body: body, expires: now.Add(c.ttl)}
+}
+
+func (c *responseCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]cachedResponse{}
+	c.gen++
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestResponseCache(t *testing.T) {
+	c := newResponseCache(time.Minute)
+	calls := 0
+	handler := c.cached("parties", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"calls":%d}`, calls)
+	})
+	write := c.invalidating(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	get := func(query string) (string, string) {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", "/parties"+query, nil))
+		return rec.Body.String(), rec.Header().Get("X-Cache")
+	}
+
+	if body, hit := get("?q=ac"); body != `{"calls":1}` || hit != "MISS" {
+		t.Fatalf("first get = %s (%s), want a miss", body, hit)
+	}
+	if body, hit := get("?q=ac"); body != `{"calls":1}` || hit != "HIT" {
+		t.Fatalf("second get = %s (%s), want the cached response", body, hit)
+	}
+	if body, _ := get("?q=be"); body != `{"calls":2}` {
+		t.Fatalf("another q = %s, want a fresh response", body)
+	}
+
+	write.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/register?fail=true", nil))
+	if _, hit := get("?q=ac"); hit != "HIT" {
+		t.Fatalf("a failed write cleared the cache")
+	}
+	write.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/register", nil))
+	if body, hit := get("?q=ac"); body != `{"calls":3}` || hit != "MISS" {
+		t.Fatalf("get after a write = %s (%s), want a fresh response", body, hit)
+	}
+}
+
+func TestResponseCacheExpires(t *testing.T) {
+	c := newResponseCache(time.Millisecond)
+	calls := 0
+	handler := c.cached("stats", func(w http.ResponseWriter, r *http.Request) { calls++ })
+
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/stats", nil))
+	time.Sleep(5 * time.Millisecond)
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "/stats", nil))
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2 once the entry expired", calls)
+	}
+}
+
+func TestResponseCacheDisabled(t *testing.T) {
+	if c := newResponseCache(0); c != nil {
+		t.Fatal("a zero TTL should turn caching off")
+	}
+}
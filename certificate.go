@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// motorCertificate renders a printable warranty certificate for a motor
+func (s *server) motorCertificate(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	motor, err := s.lookupMotor(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := renderCertificate(&buf, motor); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error generating certificate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "inline; filename=warranty-"+motor.SerialNo+".pdf")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// renderCertificate writes a one-page certificate PDF for motor to buf
+func renderCertificate(buf *bytes.Buffer, motor Motor) error {
+	expiry := motor.WarrantyEndDate
+	if expiry == "" {
+		expiry = "Not set"
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	// The core fonts are cp1252, so translate party names and the like
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 22)
+	pdf.CellFormat(0, 16, "Warranty Certificate", "", 1, "C", false, 0, "")
+	pdf.Ln(8)
+
+	rows := [][2]string{
+		{"Serial Number", motor.SerialNo},
+		{"Motor Model", motor.MotorModel},
+		{"Party", motor.PartyName},
+		{"Dispatch Date", motor.DispatchDate},
+		{"Warranty Expiry", expiry},
+		{"Warranty Status", computeWarrantyStatus(motor.WarrantyEndDate)},
+	}
+	for _, row := range rows {
+		pdf.SetFont("Helvetica", "B", 12)
+		pdf.CellFormat(60, 10, row[0], "1", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 12)
+		pdf.CellFormat(0, 10, tr(row[1]), "1", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(buf)
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type Claim struct {
+	ID              int64      `json:"id"`
+	SerialNo        string     `json:"serial_no"`
+	ClaimDate       string     `json:"claim_date"`
+	Description     string     `json:"description"`
+	Status          string     `json:"status"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// claimColumns lists the claims columns in the order scanClaim reads them
+const claimColumns = `id, serial_no, claim_date, description, status, status_changed_at, created_at`
+
+func scanClaim(row rowScanner) (Claim, error) {
+	var c Claim
+	var changed sql.NullTime
+	err := row.Scan(&c.ID, &c.SerialNo, &c.ClaimDate, &c.Description, &c.Status, &changed, &c.CreatedAt)
+	if changed.Valid {
+		c.StatusChangedAt = &changed.Time
+	}
+	return c, err
+}
+
+// claimTransitions lists the statuses a claim may move to from each status.
+// resolved and rejected are final.
+var claimTransitions = map[string][]string{
+	"open":        {"in_progress", "rejected"},
+	"in_progress": {"resolved", "rejected"},
+	"resolved":    nil,
+	"rejected":    nil,
+}
+
+// canTransition reports whether a claim may move from one status to another
+func canTransition(from, to string) bool {
+	for _, next := range claimTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// fileClaim opens a warranty claim against a motor. The claim date defaults
+// to today and must fall within the motor's warranty.
+func fileClaim(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	var claim Claim
+	err := json.NewDecoder(r.Body).Decode(&claim)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	claim.Description = strings.TrimSpace(claim.Description)
+	if claim.Description == "" {
+		writeJSONError(w, http.StatusBadRequest, "description is required")
+		return
+	}
+	if claim.ClaimDate == "" {
+		claim.ClaimDate = time.Now().Format("2006-01-02")
+	}
+	claimDate, err := time.Parse("2006-01-02", claim.ClaimDate)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "claim_date must be a valid date (YYYY-MM-DD)")
+		return
+	}
+
+	motor, err := findMotor(r.Context(), db, serial)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	// Claims are only accepted while the warranty covers the claim date
+	end, err := time.Parse("2006-01-02", motor.WarrantyEndDate)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Motor has no warranty end date on record")
+		return
+	}
+	if claimDate.After(end) {
+		writeJSONError(w, http.StatusBadRequest, "Warranty expired on "+motor.WarrantyEndDate)
+		return
+	}
+
+	stored, err := scanClaim(db.QueryRowContext(r.Context(),
+		`INSERT INTO claims (motor_id, serial_no, claim_date, description, status) VALUES ($1, $2, $3, $4, 'open') 
+         RETURNING `+claimColumns, motor.ID, motor.SerialNo, claim.ClaimDate, claim.Description))
+	if err != nil {
+		dbError(w, r, "Error inserting claim")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+// listClaims returns every claim filed against a motor, oldest first. Claims
+// against an earlier, deleted motor with the same serial aren't included.
+func listClaims(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	motor, err := findMotor(r.Context(), db, serial)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT "+claimColumns+" FROM claims WHERE motor_id = $1 ORDER BY claim_date, id", motor.ID)
+	if err != nil {
+		dbError(w, r, "Error fetching claims: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	claims := []Claim{}
+	for rows.Next() {
+		claim, err := scanClaim(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		claims = append(claims, claim)
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching claims: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+// updateClaimStatus moves a claim to a new status, enforcing claimTransitions
+func updateClaimStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+
+	var body struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if _, ok := claimTransitions[body.Status]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "Unknown status "+strconv.Quote(body.Status))
+		return
+	}
+
+	claim, err := scanClaim(db.QueryRowContext(r.Context(), "SELECT "+claimColumns+" FROM claims WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Claim not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching claim: "+err.Error())
+		return
+	}
+	if !canTransition(claim.Status, body.Status) {
+		writeJSONError(w, http.StatusConflict, "Cannot move claim from "+claim.Status+" to "+body.Status)
+		return
+	}
+
+	// Only apply the change if nobody moved the claim since we read it
+	updated, err := scanClaim(db.QueryRowContext(r.Context(),
+		`UPDATE claims SET status = $2, status_changed_at = now() WHERE id = $1 AND status = $3 
+         RETURNING `+claimColumns, id, body.Status, claim.Status))
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusConflict, "Claim status was changed by another request")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error updating claim")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
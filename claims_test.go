@@ -0,0 +1,27 @@
+package main
+
+import "testing"
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"open", "in_progress", true},
+		{"open", "rejected", true},
+		{"in_progress", "resolved", true},
+		{"in_progress", "rejected", true},
+		{"open", "resolved", false},
+		{"in_progress", "open", false},
+		{"resolved", "open", false},
+		{"rejected", "in_progress", false},
+		{"open", "open", false},
+		{"bogus", "open", false},
+	}
+
+	for _, tt := range tests {
+		if got := canTransition(tt.from, tt.to); got != tt.want {
+			t.Errorf("canTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Responses shorter than this go out uncompressed, since gzip wouldn't save
+// enough to be worth it
+const gzipMinBytes = 1400
+
+// compressibleTypes are the content types worth gzipping. PDFs and images
+// are already compressed.
+var compressibleTypes = []string{"application/json", "text/"}
+
+// gzipResponses compresses responses for clients that accept gzip once they
+// pass gzipMinBytes, provided they're of a compressibleTypes type
+func gzipResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if r.Method == "HEAD" || !acceptsGzip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipWriter{ResponseWriter: w, status: http.StatusOK}
+		defer gw.finish()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether Accept-Encoding lists gzip without q=0
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(strings.ToLower(coding)) != "gzip" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			q, err := strconv.ParseFloat(v, 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter holds back the start of a response until it knows whether to
+// compress it: either gzipMinBytes have been written or the handler is done
+type gzipWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	decided bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if !g.decided {
+		g.status = status
+	}
+}
+
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	if !g.decided {
+		g.buf = append(g.buf, b...)
+		if len(g.buf) < gzipMinBytes {
+			return len(b), nil
+		}
+		if err := g.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if g.gz != nil {
+		return g.gz.Write(b)
+	}
+	return g.ResponseWriter.Write(b)
+}
+
+// start sends the headers and anything buffered, compressing when long
+// says the response is big enough and its type is worth it
+func (g *gzipWriter) start(long bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if long && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", "gzip")
+		g.gz = gzip.NewWriter(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	if len(g.buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(g.buf)
+	} else {
+		_, err = g.ResponseWriter.Write(g.buf)
+	}
+	g.buf = nil
+	return err
+}
+
+// Flush sends what's been written so far, deciding on compression early if
+// need be
+func (g *gzipWriter) Flush() {
+	if !g.decided {
+		g.start(len(g.buf) >= gzipMinBytes)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// finish completes the response once the handler returns
+func (g *gzipWriter) finish() {
+	if !g.decided {
+		g.start(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+	}
+}
+
+func (g *gzipWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+func compressible(contentType string) bool {
+	for _, prefix := range compressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
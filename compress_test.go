@@ -0,0 +1,56 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipResponses(t *testing.T) {
+	long := strings.Repeat(`{"serial_no":"SN1"}`, 200)
+	handler := gzipResponses(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		io.WriteString(w, r.URL.Query().Get("body"))
+	}))
+
+	tests := []struct {
+		name, accept, contentType, body string
+		wantGzip                        bool
+	}{
+		{"large json", "gzip, deflate", "application/json", long, true},
+		{"large csv", "gzip", "text/csv", long, true},
+		{"small json", "gzip", "application/json", `{"ok":true}`, false},
+		{"no gzip", "deflate", "application/json", long, false},
+		{"gzip refused", "gzip;q=0", "application/json", long, false},
+		{"pdf", "gzip", "application/pdf", long, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := "type=" + tt.contentType + "&body=" + tt.body
+			r := httptest.NewRequest("GET", "/?"+strings.NewReplacer(`"`, "%22", "{", "%7B", "}", "%7D").Replace(q), nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, r)
+
+			gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding = %q, want gzip %v", rec.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			body := rec.Body.String()
+			if gzipped {
+				zr, err := gzip.NewReader(rec.Body)
+				if err != nil {
+					t.Fatalf("gzip.NewReader: %v", err)
+				}
+				b, _ := io.ReadAll(zr)
+				body = string(b)
+			}
+			if body != tt.body {
+				t.Errorf("body = %.40q..., want %.40q...", body, tt.body)
+			}
+		})
+	}
+}
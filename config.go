@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// requireEnv exits with a clear message naming every key that isn't set
+func requireEnv(keys ...string) {
+	var missing []string
+	for _, key := range keys {
+		if strings.TrimSpace(os.Getenv(key)) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		log.Fatalf("Missing required environment variables: %s", strings.Join(missing, ", "))
+	}
+}
+
+// envInt reads an integer env var, falling back to def when it's unset
+func envInt(key string, def int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q is not an integer", key, v)
+	}
+	return n
+}
+
+// envFloat reads a decimal env var, falling back to def when it's unset
+func envFloat(key string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q is not a number", key, v)
+	}
+	return f
+}
+
+// envBool reads a true/false env var, falling back to def when it's unset
+func envBool(key string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q must be true or false", key, v)
+	}
+	return b
+}
+
+// envDuration reads a duration env var such as "5m" or "30s", falling back
+// to def when it's unset
+func envDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("Invalid value for %s: %q is not a duration", key, v)
+	}
+	return d
+}
+
+// envList reads a comma-separated env var, dropping empty entries and falling
+// back to def when nothing is set
+func envList(key string, def []string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	if len(list) == 0 {
+		return def
+	}
+	return list
+}
+
+// envLogLevel reads a log level env var (debug, info, warn or error),
+// falling back to def when it's unset
+func envLogLevel(key string, def slog.Level) slog.Level {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(v)); err != nil {
+		log.Fatalf("Invalid value for %s: %q must be debug, info, warn or error", key, v)
+	}
+	return level
+}
+
+// SQL identifiers DB_SCHEMA may name: unquoted, lowercase Postgres names
+var schemaNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
+// envSchema reads the Postgres schema the tables live in from DB_SCHEMA,
+// defaulting to public. It ends up in the connection string, so only plain
+// identifiers are allowed.
+func envSchema() string {
+	v := strings.TrimSpace(os.Getenv("DB_SCHEMA"))
+	if v == "" {
+		return "public"
+	}
+	if !schemaNamePattern.MatchString(v) {
+		log.Fatalf("Invalid value for DB_SCHEMA: %q must be a lowercase identifier", v)
+	}
+	return v
+}
+
+// serverAddr returns the listen address for the port in PORT, defaulting to
+// 8080 when unset
+func serverAddr() string {
+	port := strings.TrimSpace(os.Getenv("PORT"))
+	if port == "" {
+		port = "8080"
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		log.Fatalf("Invalid value for PORT: %q is not a valid port number", port)
+	}
+	return ":" + port
+}
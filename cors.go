@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/rs/cors"
+)
+
+// CORS defaults, each overridable by a comma-separated env var
+var (
+	// The React frontend in development
+	defaultCORSOrigins = []string{"http://localhost:3000"}
+	defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	// Request headers the frontend sends beyond the CORS-safelisted ones
+	defaultCORSHeaders = []string{"Content-Type", "Authorization", "Idempotency-Key", "X-Request-ID", "If-None-Match"}
+	// Response headers the frontend may read
+	defaultCORSExposedHeaders = []string{"X-Request-ID", "ETag", "Idempotent-Replayed", "Deprecation", "Link", resultTruncatedHeader}
+)
+
+// corsOptionsFromEnv builds the CORS policy from CORS_ALLOWED_ORIGINS,
+// CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS, CORS_EXPOSED_HEADERS and
+// CORS_ALLOW_CREDENTIALS. Browsers refuse credentials from a wildcard
+// origin, so that combination is an error rather than a silent failure.
+func corsOptionsFromEnv() (cors.Options, error) {
+	opts := cors.Options{
+		AllowedOrigins:   envList("CORS_ALLOWED_ORIGINS", defaultCORSOrigins),
+		AllowedMethods:   envList("CORS_ALLOWED_METHODS", defaultCORSMethods),
+		AllowedHeaders:   envList("CORS_ALLOWED_HEADERS", defaultCORSHeaders),
+		ExposedHeaders:   envList("CORS_EXPOSED_HEADERS", defaultCORSExposedHeaders),
+		AllowCredentials: envBool("CORS_ALLOW_CREDENTIALS", true),
+	}
+	if opts.AllowCredentials && slices.Contains(opts.AllowedOrigins, "*") {
+		return cors.Options{}, errors.New("CORS_ALLOW_CREDENTIALS can't be true when CORS_ALLOWED_ORIGINS is *; list the origins instead")
+	}
+	return opts, nil
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCORSOptionsFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_HEADERS", "Content-Type, X-Custom")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "false")
+	t.Setenv("CORS_ALLOWED_ORIGINS", "*")
+
+	opts, err := corsOptionsFromEnv()
+	if err != nil {
+		t.Fatalf("corsOptionsFromEnv: %v", err)
+	}
+	if want := []string{"Content-Type", "X-Custom"}; !reflect.DeepEqual(opts.AllowedHeaders, want) {
+		t.Errorf("AllowedHeaders = %v, want %v", opts.AllowedHeaders, want)
+	}
+	if !reflect.DeepEqual(opts.AllowedMethods, defaultCORSMethods) {
+		t.Errorf("AllowedMethods = %v, want the defaults", opts.AllowedMethods)
+	}
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	if _, err := corsOptionsFromEnv(); err == nil {
+		t.Error("credentials with a wildcard origin: want an error")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+var errBadCursor = errors.New("Invalid cursor")
+
+// encodeCursor makes an opaque cursor for the page after id. Clients should
+// treat it as a token, so the encoding can change later.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errBadCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 0 {
+		return 0, errBadCursor
+	}
+	return id, nil
+}
+
+// fetchMotorCursor serves /fetch?mode=cursor: pages in id order, continuing
+// from the after cursor, with the cursor for the next page in next_cursor.
+// Pages don't shift when motors are added between requests, so this suits
+// syncing the whole table, which is why filters are optional here.
+func (s *server) fetchMotorCursor(w http.ResponseWriter, r *http.Request, filter MotorFilter, fields []string) {
+	q := r.URL.Query()
+	for _, param := range []string{"offset", "sort_by", "order"} {
+		if q.Has(param) {
+			writeJSONError(w, http.StatusBadRequest, param+" can't be used with mode=cursor")
+			return
+		}
+	}
+	limit, _, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// An empty page would have no last id to continue from
+	if limit == 0 {
+		writeJSONError(w, http.StatusBadRequest, "limit must be at least 1 with mode=cursor")
+		return
+	}
+	after, err := decodeCursor(q.Get("after"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// One extra row says whether there's another page
+	found, _, err := s.store.Fetch(r.Context(), MotorQuery{Filter: filter, Limit: limit + 1, Cursor: true, After: after, Fields: fields})
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	var next interface{}
+	if len(found) > limit {
+		found = found[:limit]
+		next = encodeCursor(found[limit-1].ID)
+	}
+
+	motors := make([]map[string]interface{}, 0, len(found))
+	for _, motor := range found {
+		motors = append(motors, selectFields(motorResponse(motor), fields))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":        motors,
+		"limit":       limit,
+		"next_cursor": next,
+	})
+}
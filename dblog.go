@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// loggingConnector wraps a database driver so that every statement run on
+// its connections is logged at debug level, wherever it comes from.
+// Statements taking slow or longer are also logged as warnings; zero turns
+// that off.
+type loggingConnector struct {
+	driver.Connector
+	slow time.Duration
+}
+
+func (c loggingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return loggingConn{conn, c.slow}, nil
+}
+
+// loggingConn passes everything through to the driver's connection, timing
+// queries and execs on the way. Arguments aren't logged, since they hold
+// customer details.
+type loggingConn struct {
+	driver.Conn
+	slow time.Duration
+}
+
+func (c loggingConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	c.logQuery(ctx, query, len(args), time.Since(start), err)
+	return rows, err
+}
+
+func (c loggingConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	c.logQuery(ctx, query, len(args), time.Since(start), err)
+	return res, err
+}
+
+func (c loggingConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c loggingConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c loggingConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c loggingConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c loggingConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c loggingConn) logQuery(ctx context.Context, query string, args int, took time.Duration, err error) {
+	if c.slow > 0 && took >= c.slow {
+		slog.WarnContext(ctx, "slow query",
+			"request_id", requestIDFrom(ctx),
+			"query", queryName(query),
+			"sql", strings.Join(strings.Fields(query), " "),
+			"duration_ms", took.Milliseconds(),
+		)
+	}
+	if !slog.Default().Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	attrs := []interface{}{
+		"request_id", requestIDFrom(ctx),
+		"sql", strings.Join(strings.Fields(query), " "),
+		"args", args,
+		"duration_ms", took.Milliseconds(),
+	}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	slog.DebugContext(ctx, "query", attrs...)
+}
+
+// The first table a statement reads from or writes into
+var queryTablePattern = regexp.MustCompile(`(?is)\b(?:from|into)\s+(\w+)`)
+
+// queryName labels a statement by its type and main table, such as
+// "SELECT motors", so slow queries can be grouped
+func queryName(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	op := strings.ToUpper(fields[0])
+	if op == "UPDATE" && len(fields) > 1 {
+		return op + " " + fields[1]
+	}
+	if m := queryTablePattern.FindStringSubmatch(query); m != nil {
+		return op + " " + m[1]
+	}
+	return op
+}
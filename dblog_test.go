@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT id, serial_no FROM motors WHERE serial_no = $1", "SELECT motors"},
+		{"\n  select count(*)\n  from claims", "SELECT claims"},
+		{"INSERT INTO audit_log (action) VALUES ($1)", "INSERT audit_log"},
+		{"UPDATE motors SET remarks = $2 WHERE serial_no = $1", "UPDATE motors"},
+		{"DELETE FROM idempotency_keys WHERE created_at < now()", "DELETE idempotency_keys"},
+		{"SAVEPOINT import_row", "SAVEPOINT"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := queryName(tt.query); got != tt.want {
+			t.Errorf("queryName(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrDocNotFound means no document is stored under the requested key
+var ErrDocNotFound = errors.New("document not found")
+
+// DocStore holds uploaded documents under slash-separated keys
+type DocStore interface {
+	// Put stores size bytes from r under key, replacing anything already
+	// there. size may be -1 when unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// newDocStoreFromEnv uses S3 when S3_ENDPOINT is set, and otherwise the
+// local directory in DOCS_DIR ("uploads" by default), which is only suitable
+// for development since it doesn't outlive the container
+func newDocStoreFromEnv() (DocStore, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		dir := os.Getenv("DOCS_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return &LocalDocStore{dir: dir}, nil
+	}
+
+	requireEnv("S3_BUCKET", "S3_ACCESS_KEY", "S3_SECRET_KEY")
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), ""),
+		Secure: os.Getenv("S3_USE_SSL") != "false",
+		Region: os.Getenv("S3_REGION"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3DocStore{client: client, bucket: os.Getenv("S3_BUCKET")}, nil
+}
+
+// LocalDocStore keeps documents as files under dir
+type LocalDocStore struct {
+	dir string
+}
+
+// file maps key to its path under s.dir. Keys are cleaned as if rooted, so
+// ".." can't climb out of it.
+func (s *LocalDocStore) file(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("invalid document key")
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(clean)), nil
+}
+
+func (s *LocalDocStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	// Don't leave a partial file behind
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
+
+func (s *LocalDocStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDocNotFound
+	}
+	return f, err
+}
+
+func (s *LocalDocStore) Delete(ctx context.Context, key string) error {
+	name, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// S3DocStore keeps documents in an S3-compatible bucket, such as AWS S3 or
+// MinIO
+type S3DocStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func (s *S3DocStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *S3DocStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, so a missing key only shows up once it's used
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrDocNotFound
+		}
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (s *S3DocStore) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalDocStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := &LocalDocStore{dir: filepath.Join(dir, "docs")}
+
+	if err := store.Put(ctx, "certificates/a.pdf", strings.NewReader("%PDF-1.4"), -1, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	doc, err := store.Get(ctx, "certificates/a.pdf")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, _ := io.ReadAll(doc)
+	doc.Close()
+	if string(got) != "%PDF-1.4" {
+		t.Errorf("Get = %q", got)
+	}
+
+	if err := store.Delete(ctx, "certificates/a.pdf"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Get(ctx, "certificates/a.pdf"); err != ErrDocNotFound {
+		t.Errorf("Get after Delete: err = %v, want ErrDocNotFound", err)
+	}
+
+	// Keys can't reach outside the store's directory
+	if err := store.Put(ctx, "../../escaped.pdf", strings.NewReader("x"), -1, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "escaped.pdf")); err == nil {
+		t.Error("Put wrote outside the store directory")
+	}
+}
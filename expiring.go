@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window used by /expiring when days isn't given
+const defaultExpiringDays = 30
+
+// expiringMotors lists live motors whose warranty is still active but ends
+// within the next days days, soonest first. Past maxResultRows the list is
+// cut short and marked truncated.
+func expiringMotors(w http.ResponseWriter, r *http.Request) {
+	days := defaultExpiringDays
+	if v := strings.TrimSpace(r.URL.Query().Get("days")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSONError(w, http.StatusBadRequest, "days must be a non-negative integer")
+			return
+		}
+		days = n
+	}
+
+	// The last day of a warranty still counts, so today's expiries are
+	// included. Dates are YYYY-MM-DD strings and compare correctly as text.
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	until := now.AddDate(0, 0, days).Format("2006-01-02")
+
+	rows, err := db.QueryContext(r.Context(), "SELECT "+motorSelectColumns+` FROM motors 
+        WHERE deleted_at IS NULL AND warranty_end_date ~ '^\d{4}-\d{2}-\d{2}$' 
+          AND warranty_end_date >= $1 AND warranty_end_date <= $2 
+        ORDER BY warranty_end_date, id LIMIT $3`, today, until, maxResultRows+1)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	motors := []map[string]interface{}{}
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		motors = append(motors, motorResponse(motor))
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+
+	// The extra row fetched says whether there were more
+	truncated := len(motors) > maxResultRows
+	if truncated {
+		motors = motors[:maxResultRows]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": motors, "days": days, "truncated": markTruncated(w, truncated)})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/csv"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Header row for CSV exports. Values are written in the same order by
+// motorCSVRecord.
+var motorCSVHeader = []string{
+	"id", "serial_no", "motor_model", "rpm", "phase", "party_name", "dispatch_date",
+	"transport_agency", "lr_eway_bill", "test_certificate", "party_address", "hp_kw", "remarks",
+	"warranty_start_date", "warranty_end_date", "party_email", "created_at", "updated_at",
+}
+
+func motorCSVRecord(m Motor) []string {
+	return []string{
+		strconv.FormatInt(m.ID, 10), m.SerialNo, m.MotorModel, strconv.Itoa(m.RPM), m.Phase, m.PartyName,
+		m.DispatchDate, m.TransportAgency, m.LREwayBill, m.TestCertificate, m.PartyAddress, m.HPKW, m.Remarks,
+		m.WarrantyStartDate, m.WarrantyEndDate, m.PartyEmail,
+		m.CreatedAt.Format(time.RFC3339), m.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// exportCSV streams the motors matching the fetch filters as a CSV download.
+// Unlike fetchMotor it isn't paginated and exports everything when no
+// filters are given. Rows are written as they're read so large exports
+// aren't held in memory.
+func exportCSV(w http.ResponseWriter, r *http.Request) {
+	filters, err := parseFetchFilters(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := addDeletedFilter(r, filters); err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	orderBy, err := parseSort(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	where, args := buildWhere(filters)
+
+	rows, err := db.QueryContext(r.Context(), "SELECT "+motorSelectColumns+" FROM motors "+where+" "+orderBy.clause(), args...)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=motors.csv")
+
+	cw := csv.NewWriter(w)
+	cw.Write(motorCSVHeader)
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			// Headers are already sent, so all we can do is stop and log
+			slog.Error("CSV export failed", "request_id", requestIDFrom(r.Context()), "error", err)
+			break
+		}
+		cw.Write(motorCSVRecord(motor))
+	}
+	if err := rows.Err(); err != nil {
+		slog.Error("CSV export failed", "request_id", requestIDFrom(r.Context()), "error", err)
+	}
+	cw.Flush()
+}
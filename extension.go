@@ -0,0 +1,130 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// WarrantyExtension records one purchase of extra warranty for a motor
+type WarrantyExtension struct {
+	ID              int64     `json:"id"`
+	SerialNo        string    `json:"serial_no"`
+	Months          int       `json:"months"`
+	PreviousEndDate string    `json:"previous_end_date"`
+	NewEndDate      string    `json:"new_end_date"`
+	Actor           string    `json:"actor"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// extensionColumns lists the warranty_extensions columns in the order
+// scanExtension reads them
+const extensionColumns = `id, serial_no, months, previous_end_date, new_end_date, actor, created_at`
+
+func scanExtension(row rowScanner) (WarrantyExtension, error) {
+	var e WarrantyExtension
+	err := row.Scan(&e.ID, &e.SerialNo, &e.Months, &e.PreviousEndDate, &e.NewEndDate, &e.Actor, &e.CreatedAt)
+	return e, err
+}
+
+// extendedEndDate returns the warranty end after adding months to one
+// ending on end. An expired warranty is extended from today, since the
+// lapsed time can't be covered after the fact.
+func extendedEndDate(end, today time.Time, months int) time.Time {
+	from := end
+	if today.After(end) {
+		from = today
+	}
+	return addMonths(from, months)
+}
+
+// addMonths moves t on by months, keeping its day of the month but clamping
+// it to the last day of a shorter target month. time.AddDate would instead
+// roll Jan 31 + 1 month over to early March.
+func addMonths(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, 0, 0, 0, 0, t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(t.Day(), lastDay),
+		t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// extendWarranty adds the months in the request body to a motor's warranty
+// and records the extension. Expired warranties are refused unless the body
+// sets allow_expired.
+func extendWarranty(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	var req struct {
+		Months       int  `json:"months"`
+		AllowExpired bool `json:"allow_expired"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if req.Months <= 0 || req.Months > maxPolicyMonths {
+		writeJSONError(w, http.StatusBadRequest, "months must be between 1 and "+strconv.Itoa(maxPolicyMonths))
+		return
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		dbError(w, r, "Error extending warranty")
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the motor so two extensions can't both start from the same end date
+	motor, err := scanMotor(tx.QueryRowContext(r.Context(),
+		"SELECT "+motorSelectColumns+" FROM motors WHERE "+liveSerialMatch+" FOR UPDATE", serial))
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+	end, err := time.Parse("2006-01-02", motor.WarrantyEndDate)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Motor has no warranty end date on record")
+		return
+	}
+	if computeWarrantyStatus(motor.WarrantyEndDate) == "expired" && !req.AllowExpired {
+		writeJSONError(w, http.StatusBadRequest, "Warranty expired on "+motor.WarrantyEndDate+"; set allow_expired to extend it anyway")
+		return
+	}
+	today, _ := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	newEnd := extendedEndDate(end, today, req.Months).Format("2006-01-02")
+
+	updated, err := scanMotor(tx.QueryRowContext(r.Context(),
+		`UPDATE motors SET warranty_end_date = $2, updated_at = now(), version = version + 1
+         WHERE id = $1 RETURNING `+motorSelectColumns, motor.ID, newEnd))
+	if err != nil {
+		dbError(w, r, "Error extending warranty")
+		return
+	}
+	extension, err := scanExtension(tx.QueryRowContext(r.Context(),
+		`INSERT INTO warranty_extensions (motor_id, serial_no, months, previous_end_date, new_end_date, actor)
+         VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+extensionColumns,
+		motor.ID, motor.SerialNo, req.Months, motor.WarrantyEndDate, newEnd, auditActor(r.Context())))
+	if err == nil {
+		err = writeAudit(r.Context(), tx, "extend", updated)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		dbError(w, r, "Error extending warranty")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"motor":     motorResponse(updated),
+		"extension": extension,
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExtendedEndDate(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name, end, today, want string
+		months                 int
+	}{
+		{"active", "2025-06-30", "2025-01-15", "2026-06-30", 12},
+		{"ends today", "2025-01-15", "2025-01-15", "2025-07-15", 6},
+		{"expired restarts today", "2024-03-01", "2025-01-15", "2025-04-15", 3},
+		{"month end clamps", "2025-01-31", "2025-01-15", "2025-02-28", 1},
+		{"month end into leap February", "2024-01-31", "2024-01-15", "2024-02-29", 1},
+		{"leap day a year on", "2024-02-29", "2024-01-15", "2025-02-28", 12},
+		{"leap day four years on", "2024-02-29", "2024-01-15", "2028-02-29", 48},
+		{"31st into a 30-day month", "2025-08-31", "2025-01-15", "2025-09-30", 1},
+		{"across a year end", "2025-10-31", "2025-01-15", "2026-02-28", 4},
+	}
+	for _, tt := range tests {
+		got := extendedEndDate(day(tt.end), day(tt.today), tt.months).Format("2006-01-02")
+		if got != tt.want {
+			t.Errorf("%s: extendedEndDate(%s, %s, %d) = %s, want %s", tt.name, tt.end, tt.today, tt.months, got, tt.want)
+		}
+	}
+}
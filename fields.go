@@ -0,0 +1,146 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// parseFields reads the optional fields query param, a comma-separated
+// list of motor response fields to return. serial_no is always included so
+// each record can be identified. nil means every field.
+func parseFields(r *http.Request) ([]string, error) {
+	v := strings.TrimSpace(r.URL.Query().Get("fields"))
+	if v == "" {
+		return nil, nil
+	}
+	known := motorResponse(Motor{})
+	fields := []string{"serial_no"}
+	seen := map[string]bool{"serial_no": true}
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q in fields", name)
+		}
+		seen[name] = true
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// selectFields trims a motorResponse down to fields, leaving it whole when
+// fields is nil
+func selectFields(resp map[string]interface{}, fields []string) map[string]interface{} {
+	if fields == nil {
+		return resp
+	}
+	sparse := make(map[string]interface{}, len(fields))
+	for _, name := range fields {
+		sparse[name] = resp[name]
+	}
+	return sparse
+}
+
+// fieldColumns names the motors columns each response field is read from.
+// warranty_status is derived from warranty_end_date at read time.
+var fieldColumns = map[string][]string{
+	"id":                  {"id"},
+	"serial_no":           {"serial_no"},
+	"motor_model":         {"motor_model"},
+	"rpm":                 {"rpm"},
+	"phase":               {"phase"},
+	"party_name":          {"party_name"},
+	"dispatch_date":       {"dispatch_date"},
+	"transport_agency":    {"transport_agency"},
+	"lr_eway_bill":        {"lr_or_eway_bill"},
+	"test_certificate":    {"test_certificate"},
+	"party_address":       {"party_address"},
+	"party_email":         {"party_email"},
+	"hp_kw":               {"hp_kw"},
+	"power_kw":            {"power_kw"},
+	"remarks":             {"remarks"},
+	"warranty_start_date": {"warranty_start_date"},
+	"warranty_end_date":   {"warranty_end_date"},
+	"warranty_status":     {"warranty_end_date"},
+	"created_at":          {"created_at"},
+	"updated_at":          {"updated_at"},
+	"version":             {"version"},
+}
+
+// selectColumns lists the columns to read for fields, in motorSelectColumns
+// order. id is always read, as cursor paging continues from it. nil fields
+// reads every column.
+func selectColumns(fields []string) []string {
+	all := strings.Split(motorSelectColumns, ",")
+	for i := range all {
+		all[i] = strings.TrimSpace(all[i])
+	}
+	if fields == nil {
+		return all
+	}
+	want := map[string]bool{"id": true}
+	for _, name := range fields {
+		for _, col := range fieldColumns[name] {
+			want[col] = true
+		}
+	}
+	columns := make([]string, 0, len(want))
+	for _, col := range all {
+		if want[col] {
+			columns = append(columns, col)
+		}
+	}
+	return columns
+}
+
+// nullableText scans a text column into a string, reading NULL as ""
+type nullableText struct{ s *string }
+
+func (n nullableText) Scan(v interface{}) error {
+	var ns sql.NullString
+	err := ns.Scan(v)
+	*n.s = ns.String
+	return err
+}
+
+// motorColumnDests gives where scanMotorColumns stores each column of m.
+// The columns the original schema left nullable read NULL as "", as in
+// scanMotor.
+var motorColumnDests = map[string]func(m *Motor) interface{}{
+	"id":                  func(m *Motor) interface{} { return &m.ID },
+	"serial_no":           func(m *Motor) interface{} { return &m.SerialNo },
+	"motor_model":         func(m *Motor) interface{} { return &m.MotorModel },
+	"rpm":                 func(m *Motor) interface{} { return &m.RPM },
+	"phase":               func(m *Motor) interface{} { return &m.Phase },
+	"party_name":          func(m *Motor) interface{} { return &m.PartyName },
+	"dispatch_date":       func(m *Motor) interface{} { return &m.DispatchDate },
+	"transport_agency":    func(m *Motor) interface{} { return nullableText{&m.TransportAgency} },
+	"lr_or_eway_bill":     func(m *Motor) interface{} { return nullableText{&m.LREwayBill} },
+	"test_certificate":    func(m *Motor) interface{} { return nullableText{&m.TestCertificate} },
+	"party_address":       func(m *Motor) interface{} { return nullableText{&m.PartyAddress} },
+	"hp_kw":               func(m *Motor) interface{} { return nullableText{&m.HPKW} },
+	"remarks":             func(m *Motor) interface{} { return nullableText{&m.Remarks} },
+	"warranty_start_date": func(m *Motor) interface{} { return nullableText{&m.WarrantyStartDate} },
+	"warranty_end_date":   func(m *Motor) interface{} { return nullableText{&m.WarrantyEndDate} },
+	"party_email":         func(m *Motor) interface{} { return &m.PartyEmail },
+	"power_kw":            func(m *Motor) interface{} { return &m.PowerKW },
+	"created_at":          func(m *Motor) interface{} { return &m.CreatedAt },
+	"updated_at":          func(m *Motor) interface{} { return &m.UpdatedAt },
+	"version":             func(m *Motor) interface{} { return &m.Version },
+}
+
+// scanMotorColumns reads a row selected with columns, as given by
+// selectColumns. Columns that weren't selected are left zero.
+func scanMotorColumns(row rowScanner, columns []string) (Motor, error) {
+	var motor Motor
+	dest := make([]interface{}, len(columns))
+	for i, col := range columns {
+		dest[i] = motorColumnDests[col](&motor)
+	}
+	err := row.Scan(dest...)
+	return motor, err
+}
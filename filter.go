@@ -0,0 +1,307 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MotorFilter narrows a motor listing. Empty fields don't filter anything,
+// so the zero value matches every live motor.
+type MotorFilter struct {
+	SerialNo string
+	// PartyNames matches motors belonging to any of the listed parties
+	PartyNames []string
+	// PartySearch makes the single entry in PartyNames a case-insensitive
+	// substring match
+	PartySearch bool
+	MotorModel  string
+	Phase       string
+	// TransportAgency matches motors shipped by that agency exactly
+	TransportAgency string
+	// LREwayBill matches every motor shipped on that LR or eway bill
+	LREwayBill   string
+	DispatchFrom string
+	DispatchTo   string
+	// MinKW and MaxKW bound power_kw; motors without a parsed rating never
+	// match either
+	MinKW *float64
+	MaxKW *float64
+	// MinRPM and MaxRPM bound rpm
+	MinRPM *int
+	MaxRPM *int
+	// IncludeDeleted also matches soft-deleted motors
+	IncludeDeleted bool
+}
+
+// isEmpty reports whether f sets none of the caller's filters.
+// IncludeDeleted only widens a filter, so it doesn't count.
+func (f MotorFilter) isEmpty() bool {
+	return f.SerialNo == "" && len(f.PartyNames) == 0 && f.MotorModel == "" && f.Phase == "" &&
+		f.TransportAgency == "" && f.LREwayBill == "" && f.DispatchFrom == "" && f.DispatchTo == "" &&
+		f.MinKW == nil && f.MaxKW == nil && f.MinRPM == nil && f.MaxRPM == nil
+}
+
+// Most party names one fetch may ask for
+const maxPartyNames = 100
+
+// parsePartyNames collects party_name values, which may be repeated and may
+// each hold a comma-separated list
+func parsePartyNames(r *http.Request) ([]string, error) {
+	var names []string
+	for _, v := range r.URL.Query()["party_name"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) > maxPartyNames {
+		return nil, fmt.Errorf("at most %d party_name values are allowed", maxPartyNames)
+	}
+	return names, nil
+}
+
+// parseMotorFilter reads the fetch query params into a MotorFilter. It
+// doesn't look at include_deleted; see includeDeleted.
+func parseMotorFilter(r *http.Request) (MotorFilter, error) {
+	q := r.URL.Query()
+	from, to, err := parseDispatchRange(r)
+	if err != nil {
+		return MotorFilter{}, err
+	}
+	parties, err := parsePartyNames(r)
+	if err != nil {
+		return MotorFilter{}, err
+	}
+	minKW, maxKW, err := parseKWRange(r)
+	if err != nil {
+		return MotorFilter{}, err
+	}
+	minRPM, maxRPM, err := parseRPMRange(r)
+	if err != nil {
+		return MotorFilter{}, err
+	}
+	search := q.Get("search") == "true"
+	if search && len(parties) > 1 {
+		return MotorFilter{}, errors.New("search takes a single party_name")
+	}
+
+	return MotorFilter{
+		SerialNo:        normalizeSerial(q.Get("serial_no")),
+		PartyNames:      parties,
+		PartySearch:     search,
+		MotorModel:      strings.TrimSpace(q.Get("motor_model")),
+		Phase:           normalizePhase(strings.TrimSpace(q.Get("phase"))),
+		TransportAgency: strings.TrimSpace(q.Get("transport_agency")),
+		LREwayBill:      strings.TrimSpace(q.Get("lr_eway_bill")),
+		DispatchFrom:    from,
+		DispatchTo:      to,
+		MinKW:           minKW,
+		MaxKW:           maxKW,
+		MinRPM:          minRPM,
+		MaxRPM:          maxRPM,
+	}, nil
+}
+
+// conditions turns f into a filter set for buildWhere. The deleted_at check
+// is left to the caller.
+func (f MotorFilter) conditions() map[string]interface{} {
+	filters := map[string]interface{}{}
+
+	if f.SerialNo != "" {
+		// Also matches motors stored before serials were normalized
+		filters["(serial_no = $%[1]d OR upper(trim(serial_no)) = $%[1]d)"] = f.SerialNo
+	}
+	switch {
+	case len(f.PartyNames) == 1 && f.PartySearch:
+		filters[`party_name ILIKE '%%' || $%d || '%%'`] = escapeLike(f.PartyNames[0])
+	case len(f.PartyNames) == 1:
+		filters["party_name = $%d"] = f.PartyNames[0]
+	case len(f.PartyNames) > 1:
+		filters["party_name IN (%s)"] = f.PartyNames
+	}
+	if f.MotorModel != "" {
+		filters["motor_model = $%d"] = f.MotorModel
+	}
+	if f.Phase != "" {
+		filters["phase = $%d"] = f.Phase
+	}
+	if f.TransportAgency != "" {
+		filters["transport_agency = $%d"] = f.TransportAgency
+	}
+	if f.LREwayBill != "" {
+		filters["lr_or_eway_bill = $%d"] = f.LREwayBill
+	}
+	// Together these two are dispatch_date BETWEEN from AND to
+	if f.DispatchFrom != "" {
+		filters["dispatch_date >= $%d"] = f.DispatchFrom
+	}
+	if f.DispatchTo != "" {
+		filters["dispatch_date <= $%d"] = f.DispatchTo
+	}
+	if f.MinKW != nil {
+		filters["power_kw >= $%d"] = *f.MinKW
+	}
+	if f.MaxKW != nil {
+		filters["power_kw <= $%d"] = *f.MaxKW
+	}
+	// Together these two are rpm BETWEEN min AND max
+	if f.MinRPM != nil {
+		filters["rpm >= $%d"] = *f.MinRPM
+	}
+	if f.MaxRPM != nil {
+		filters["rpm <= $%d"] = *f.MaxRPM
+	}
+
+	return filters
+}
+
+// parseFetchFilters reads the fetch query params into a filter set for
+// buildWhere. Params left empty don't filter anything.
+func parseFetchFilters(r *http.Request) (map[string]interface{}, error) {
+	f, err := parseMotorFilter(r)
+	if err != nil {
+		return nil, err
+	}
+	return f.conditions(), nil
+}
+
+// includeDeleted reports whether the request asked for soft-deleted motors
+// with include_deleted=true, which only admins may do
+func includeDeleted(r *http.Request) (bool, error) {
+	if r.URL.Query().Get("include_deleted") != "true" {
+		return false, nil
+	}
+	if !isAdmin(r) {
+		return false, errors.New("include_deleted requires an admin token")
+	}
+	return true, nil
+}
+
+// addDeletedFilter hides soft-deleted motors unless an admin asked for them
+// with include_deleted=true
+func addDeletedFilter(r *http.Request, filters map[string]interface{}) error {
+	include, err := includeDeleted(r)
+	if err != nil {
+		return err
+	}
+	if !include {
+		filters["deleted_at IS NULL"] = nil
+	}
+	return nil
+}
+
+// buildWhere turns a filter set into a WHERE clause and its bound args. Each
+// key is a condition with one %d placeholder for the position of its value,
+// written %[1]d to use the value more than once, or a literal condition when
+// the value is nil. A []string value binds each element, and its key has a
+// %s placeholder for the list of positions, as in "col IN (%s)". Conditions
+// are joined with AND in sorted order so the SQL is deterministic. An empty
+// filter set gives an empty clause.
+func buildWhere(filters map[string]interface{}) (string, []interface{}) {
+	if len(filters) == 0 {
+		return "", nil
+	}
+
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	conds := make([]string, 0, len(keys))
+	args := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		if filters[k] == nil {
+			conds = append(conds, k)
+			continue
+		}
+		if list, ok := filters[k].([]string); ok {
+			placeholders := make([]string, len(list))
+			for i, v := range list {
+				args = append(args, v)
+				placeholders[i] = fmt.Sprintf("$%d", len(args))
+			}
+			conds = append(conds, fmt.Sprintf(k, strings.Join(placeholders, ", ")))
+			continue
+		}
+		args = append(args, filters[k])
+		conds = append(conds, fmt.Sprintf(k, len(args)))
+	}
+	return "WHERE " + strings.Join(conds, " AND "), args
+}
+
+// parseDispatchRange reads the optional dispatch_from and dispatch_to query
+// params, checking both are YYYY-MM-DD dates in order
+func parseDispatchRange(r *http.Request) (string, string, error) {
+	from := strings.TrimSpace(r.URL.Query().Get("dispatch_from"))
+	to := strings.TrimSpace(r.URL.Query().Get("dispatch_to"))
+
+	var fromDate, toDate time.Time
+	var err error
+	if from != "" {
+		if fromDate, err = time.Parse("2006-01-02", from); err != nil {
+			return "", "", errors.New("dispatch_from must be a valid date (YYYY-MM-DD)")
+		}
+	}
+	if to != "" {
+		if toDate, err = time.Parse("2006-01-02", to); err != nil {
+			return "", "", errors.New("dispatch_to must be a valid date (YYYY-MM-DD)")
+		}
+	}
+	if from != "" && to != "" && fromDate.After(toDate) {
+		return "", "", errors.New("dispatch_from must not be after dispatch_to")
+	}
+	return from, to, nil
+}
+
+// parseKWRange reads the optional min_kw and max_kw query params
+func parseKWRange(r *http.Request) (*float64, *float64, error) {
+	var bounds [2]*float64
+	for i, name := range []string{"min_kw", "max_kw"} {
+		v := strings.TrimSpace(r.URL.Query().Get(name))
+		if v == "" {
+			continue
+		}
+		kw, err := strconv.ParseFloat(v, 64)
+		if err != nil || kw < 0 || math.IsInf(kw, 0) || math.IsNaN(kw) {
+			return nil, nil, fmt.Errorf("%s must be a non-negative number", name)
+		}
+		bounds[i] = &kw
+	}
+	if bounds[0] != nil && bounds[1] != nil && *bounds[0] > *bounds[1] {
+		return nil, nil, errors.New("min_kw must not be greater than max_kw")
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// parseRPMRange reads the optional min_rpm and max_rpm query params
+func parseRPMRange(r *http.Request) (*int, *int, error) {
+	var bounds [2]*int
+	for i, name := range []string{"min_rpm", "max_rpm"} {
+		v := strings.TrimSpace(r.URL.Query().Get(name))
+		if v == "" {
+			continue
+		}
+		rpm, err := strconv.Atoi(v)
+		if err != nil || rpm < 0 {
+			return nil, nil, fmt.Errorf("%s must be a non-negative integer", name)
+		}
+		bounds[i] = &rpm
+	}
+	if bounds[0] != nil && bounds[1] != nil && *bounds[0] > *bounds[1] {
+		return nil, nil, errors.New("min_rpm must not be greater than max_rpm")
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildWhere(t *testing.T) {
+	tests := []struct {
+		name      string
+		filters   map[string]interface{}
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no filters",
+			filters:   map[string]interface{}{},
+			wantWhere: "",
+			wantArgs:  nil,
+		},
+		{
+			name:      "one filter",
+			filters:   map[string]interface{}{"serial_no = $%d": "SN1"},
+			wantWhere: "WHERE serial_no = $1",
+			wantArgs:  []interface{}{"SN1"},
+		},
+		{
+			name: "multiple filters",
+			filters: map[string]interface{}{
+				"serial_no = $%d":      "SN1",
+				"party_name = $%d":     "Acme",
+				"dispatch_date >= $%d": "2024-01-01",
+			},
+			wantWhere: "WHERE dispatch_date >= $1 AND party_name = $2 AND serial_no = $3",
+			wantArgs:  []interface{}{"2024-01-01", "Acme", "SN1"},
+		},
+		{
+			name: "literal condition",
+			filters: map[string]interface{}{
+				"deleted_at IS NULL": nil,
+				"serial_no = $%d":    "SN1",
+			},
+			wantWhere: "WHERE deleted_at IS NULL AND serial_no = $1",
+			wantArgs:  []interface{}{"SN1"},
+		},
+		{
+			name: "list filter",
+			filters: map[string]interface{}{
+				"party_name IN (%s)": []string{"Acme", "Bolt"},
+				"serial_no = $%d":    "SN1",
+			},
+			wantWhere: "WHERE party_name IN ($1, $2) AND serial_no = $3",
+			wantArgs:  []interface{}{"Acme", "Bolt", "SN1"},
+		},
+		{
+			name: "value used twice",
+			filters: map[string]interface{}{
+				"party_name = $%d": "Acme",
+				"(serial_no = $%[1]d OR upper(trim(serial_no)) = $%[1]d)": "SN1",
+			},
+			wantWhere: "WHERE (serial_no = $1 OR upper(trim(serial_no)) = $1) AND party_name = $2",
+			wantArgs:  []interface{}{"SN1", "Acme"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := buildWhere(tt.filters)
+			if where != tt.wantWhere {
+				t.Errorf("where = %q, want %q", where, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestParseFetchFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name:  "empty params are ignored",
+			query: "serial_no=&party_name=%20&motor_model=&phase=",
+			want:  map[string]interface{}{},
+		},
+		{
+			name:  "serial and party",
+			query: "serial_no=%20sn1&party_name=Acme",
+			want: map[string]interface{}{
+				"(serial_no = $%[1]d OR upper(trim(serial_no)) = $%[1]d)": "SN1",
+				"party_name = $%d": "Acme",
+			},
+		},
+		{
+			name:  "several parties",
+			query: "party_name=Acme,%20Bolt&party_name=Core",
+			want: map[string]interface{}{
+				"party_name IN (%s)": []string{"Acme", "Bolt", "Core"},
+			},
+		},
+		{
+			name:    "search with several parties",
+			query:   "party_name=Acme,Bolt&search=true",
+			wantErr: true,
+		},
+		{
+			name:    "too many parties",
+			query:   "party_name=" + strings.Repeat("P,", maxPartyNames+1),
+			wantErr: true,
+		},
+		{
+			name:  "model and phase",
+			query: "motor_model=MX-100&phase=3-Phase",
+			want: map[string]interface{}{
+				"motor_model = $%d": "MX-100",
+				"phase = $%d":       "three",
+			},
+		},
+		{
+			name:  "transport agency",
+			query: "transport_agency=%20VRL%20Logistics&party_name=",
+			want: map[string]interface{}{
+				"transport_agency = $%d": "VRL Logistics",
+			},
+		},
+		{
+			name:  "lr or eway bill",
+			query: "lr_eway_bill=%20LR-4471%20",
+			want: map[string]interface{}{
+				"lr_or_eway_bill = $%d": "LR-4471",
+			},
+		},
+		{
+			name:  "party substring search",
+			query: "party_name=50%25&search=true",
+			want: map[string]interface{}{
+				`party_name ILIKE '%%' || $%d || '%%'`: `50\%`,
+			},
+		},
+		{
+			name:  "dispatch range",
+			query: "dispatch_from=2024-01-01&dispatch_to=2024-01-31",
+			want: map[string]interface{}{
+				"dispatch_date >= $%d": "2024-01-01",
+				"dispatch_date <= $%d": "2024-01-31",
+			},
+		},
+		{
+			name:    "dispatch range out of order",
+			query:   "dispatch_from=2024-02-01&dispatch_to=2024-01-01",
+			wantErr: true,
+		},
+		{
+			name:    "malformed date",
+			query:   "dispatch_from=01/02/2024",
+			wantErr: true,
+		},
+		{
+			name:  "power range",
+			query: "min_kw=2.2&max_kw=7.5",
+			want: map[string]interface{}{
+				"power_kw >= $%d": 2.2,
+				"power_kw <= $%d": 7.5,
+			},
+		},
+		{
+			name:    "power range out of order",
+			query:   "min_kw=7.5&max_kw=2.2",
+			wantErr: true,
+		},
+		{
+			name:  "rpm range",
+			query: "party_name=Acme&min_rpm=1400&max_rpm=1500",
+			want: map[string]interface{}{
+				"party_name = $%d": "Acme",
+				"rpm >= $%d":       1400,
+				"rpm <= $%d":       1500,
+			},
+		},
+		{
+			name:  "rpm lower bound only",
+			query: "min_rpm=2800",
+			want:  map[string]interface{}{"rpm >= $%d": 2800},
+		},
+		{
+			name:    "rpm range out of order",
+			query:   "min_rpm=1500&max_rpm=1400",
+			wantErr: true,
+		},
+		{
+			name:    "malformed rpm",
+			query:   "max_rpm=1440.5",
+			wantErr: true,
+		},
+		{
+			name:    "malformed power",
+			query:   "min_kw=5hp",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/fetch?"+tt.query, nil)
+			got, err := parseFetchFilters(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filters = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
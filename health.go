@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// How long a health check waits on the database before reporting it down
+const healthCheckTimeout = 2 * time.Second
+
+// healthCheck reports whether the service can reach Postgres. It only pings
+// the connection, so it's cheap enough to poll frequently.
+func healthCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	// There's no database to check when running on the in-memory store
+	if db != nil {
+		if err := db.PingContext(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "unavailable"})
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// livenessCheck answers as long as the process is serving requests. It
+// never touches the database, so an outage there doesn't get the process
+// restarted.
+func livenessCheck(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// readinessCheck reports whether the service can take traffic: Postgres
+// answers and every embedded migration has been applied
+func readinessCheck(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "application/json")
+	if db != nil {
+		reason := ""
+		if err := db.PingContext(ctx); err != nil {
+			reason = "database unreachable"
+		} else if pending, err := pendingMigrations(ctx, db); err != nil {
+			reason = "could not read schema_migrations"
+		} else if pending > 0 {
+			reason = "migrations pending"
+		}
+		if reason != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "unavailable", "reason": reason})
+			return
+		}
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
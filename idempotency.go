@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// How long a saved response answers repeats of its Idempotency-Key
+const idempotencyTTL = 24 * time.Hour
+
+// Longest Idempotency-Key accepted
+const maxIdempotencyKeyLen = 255
+
+// IdempotentResponse is a response saved under an Idempotency-Key, along
+// with a hash of the request that produced it
+type IdempotentResponse struct {
+	Fingerprint string
+	Status      int
+	Body        []byte
+}
+
+// IdempotencyStore saves responses by the caller and Idempotency-Key so
+// retried requests get the original answer. Each caller has its own keys,
+// so one can't see another's responses by reusing a key. Responses older
+// than idempotencyTTL are forgotten.
+type IdempotencyStore interface {
+	LookupResponse(ctx context.Context, actor, key string) (IdempotentResponse, bool, error)
+	SaveResponse(ctx context.Context, actor, key string, resp IdempotentResponse) error
+}
+
+// responseCapture copies what a handler writes so it can be saved
+type responseCapture struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (c *responseCapture) WriteHeader(status int) {
+	c.status = status
+	c.ResponseWriter.WriteHeader(status)
+}
+
+func (c *responseCapture) Write(b []byte) (int, error) {
+	c.body.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+// idempotent makes next safe to retry with an Idempotency-Key header: the
+// first response under a caller's key is saved, and repeats of the same
+// request from that caller get it back instead of running next again. Requests without the header, or
+// servers without s.keys, go straight through.
+func (s *server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimSpace(r.Header.Get("Idempotency-Key"))
+		// Dry runs store nothing, and saving one would make the real request
+		// that follows it look like a retry
+		if key == "" || s.keys == nil || r.URL.Query().Get("dry_run") == "true" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeJSONError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid input")
+			return
+		}
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+
+		actor := auditActor(r.Context())
+		saved, ok, err := s.keys.LookupResponse(r.Context(), actor, key)
+		if err != nil {
+			dbError(w, r, "Error looking up idempotency key: "+err.Error())
+			return
+		}
+		if ok {
+			if saved.Fingerprint != fingerprint {
+				writeJSONError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(saved.Status)
+			w.Write(saved.Body)
+			return
+		}
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		rec := &responseCapture{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		// Server errors may not happen again, so the client is free to retry
+		if rec.status >= 500 {
+			return
+		}
+		err = s.keys.SaveResponse(r.Context(), actor, key, IdempotentResponse{
+			Fingerprint: fingerprint, Status: rec.status, Body: rec.body.Bytes(),
+		})
+		if err != nil {
+			slog.Warn("Saving idempotent response failed", "request_id", requestIDFrom(r.Context()), "error", err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIdempotentRegister(t *testing.T) {
+	store := newInMemoryStore()
+	s := &server{store: store, keys: store}
+	handler := s.idempotent(s.registerMotor)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		return rec
+	}
+	body := `{"serial_no":"SN1","motor_model":"M1","rpm":1440,"phase":"three","party_name":"Acme","dispatch_date":"2024-01-10"}`
+
+	first := post("key-1", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first: status = %d, want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+
+	// A retry gets the original response rather than a duplicate-serial 409
+	retry := post("key-1", body)
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry: status = %d, body = %s; want the first response", retry.Code, retry.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("retry: missing Idempotent-Replayed header")
+	}
+	if _, total, _ := store.Fetch(context.Background(), MotorQuery{Filter: MotorFilter{SerialNo: "SN1"}, Limit: 10}); total != 1 {
+		t.Errorf("stored %d motors, want 1", total)
+	}
+
+	other := post("key-1", strings.Replace(body, "SN1", "SN2", 1))
+	if other.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key: status = %d, want %d", other.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+// Keys are per caller, so another caller reusing one runs its own request
+func TestIdempotencyKeysPerActor(t *testing.T) {
+	store := newInMemoryStore()
+	s := &server{store: store, keys: store}
+	handler := s.idempotent(s.registerMotor)
+
+	post := func(actor, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/register", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), claimsKey, jwt.MapClaims{"sub": actor}))
+		r.Header.Set("Idempotency-Key", "shared-key")
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		return rec
+	}
+	body := `{"serial_no":"SN1","motor_model":"M1","rpm":1440,"phase":"three","party_name":"Acme","dispatch_date":"2024-01-10"}`
+
+	if rec := post("alice", body); rec.Code != http.StatusCreated {
+		t.Fatalf("alice: status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body)
+	}
+	// bob's identical request runs, and hits the duplicate serial
+	rec := post("bob", body)
+	if rec.Code != http.StatusConflict || rec.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("bob, same body: status = %d, replayed = %q; want %d, not replayed",
+			rec.Code, rec.Header().Get("Idempotent-Replayed"), http.StatusConflict)
+	}
+	other := strings.Replace(body, "SN1", "SN2", 1)
+	if rec := post("carol", other); rec.Code != http.StatusCreated {
+		t.Errorf("carol, other body: status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if rec := post("alice", body); rec.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("alice retry: missing Idempotent-Replayed header")
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Upload size cap when IMPORT_MAX_BYTES isn't set
+const defaultImportMaxBytes = 10 << 20
+
+// Most data rows one import may hold
+const maxImportRows = 10000
+
+// Import columns that are ignored rather than rejected, so a CSV export can
+// be imported again. The server assigns these itself.
+var importIgnoredColumns = map[string]bool{"id": true, "created_at": true, "updated_at": true}
+
+// importSetters fill in a motor field from its CSV column
+var importSetters = map[string]func(m *Motor, v string) error{
+	"serial_no":   func(m *Motor, v string) error { m.SerialNo = v; return nil },
+	"motor_model": func(m *Motor, v string) error { m.MotorModel = v; return nil },
+	"rpm": func(m *Motor, v string) error {
+		if v == "" {
+			return nil
+		}
+		rpm, err := strconv.Atoi(v)
+		if err != nil {
+			return errors.New("rpm must be a whole number")
+		}
+		m.RPM = rpm
+		return nil
+	},
+	"phase":               func(m *Motor, v string) error { m.Phase = v; return nil },
+	"party_name":          func(m *Motor, v string) error { m.PartyName = v; return nil },
+	"dispatch_date":       func(m *Motor, v string) error { m.DispatchDate = v; return nil },
+	"transport_agency":    func(m *Motor, v string) error { m.TransportAgency = v; return nil },
+	"lr_eway_bill":        func(m *Motor, v string) error { m.LREwayBill = v; return nil },
+	"test_certificate":    func(m *Motor, v string) error { m.TestCertificate = v; return nil },
+	"party_address":       func(m *Motor, v string) error { m.PartyAddress = v; return nil },
+	"hp_kw":               func(m *Motor, v string) error { m.HPKW = v; return nil },
+	"remarks":             func(m *Motor, v string) error { m.Remarks = v; return nil },
+	"warranty_start_date": func(m *Motor, v string) error { m.WarrantyStartDate = v; return nil },
+	"warranty_end_date":   func(m *Motor, v string) error { m.WarrantyEndDate = v; return nil },
+	"party_email":         func(m *Motor, v string) error { m.PartyEmail = v; return nil },
+}
+
+// importRowError reports why one CSV row wasn't imported
+type importRowError struct {
+	Line     int    `json:"line"`
+	SerialNo string `json:"serial_no,omitempty"`
+	Error    string `json:"error"`
+}
+
+// importMotorsCSV registers the motors in an uploaded CSV, whose header row
+// names the motor columns in any order. Bad rows are skipped and reported;
+// with strict=true any bad row fails the whole import and nothing is stored.
+func importMotorsCSV(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(envInt("IMPORT_MAX_BYTES", defaultImportMaxBytes)))
+	strict := r.URL.Query().Get("strict") == "true"
+
+	file, _, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "A multipart file field named file is required")
+		return
+	}
+	defer file.Close()
+
+	cr := csv.NewReader(file)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Could not read the CSV header row")
+		return
+	}
+	setters := make([]func(*Motor, string) error, len(header))
+	for i, name := range header {
+		// Spreadsheet exports often start with a byte order mark
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if importIgnoredColumns[name] {
+			continue
+		}
+		set, ok := importSetters[name]
+		if !ok {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Unknown column %q", name))
+			return
+		}
+		setters[i] = set
+	}
+
+	tx, err := db.BeginTx(r.Context(), nil)
+	if err != nil {
+		dbError(w, r, "Error importing data")
+		return
+	}
+	defer tx.Rollback()
+
+	inserted, rowCount := 0, 0
+	failures := []importRowError{}
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Malformed CSV at line %d", line))
+			return
+		}
+		if rowCount++; rowCount > maxImportRows {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("At most %d rows may be imported at once", maxImportRows))
+			return
+		}
+		if err != nil {
+			failures = append(failures, importRowError{Line: line, Error: "wrong number of fields"})
+			continue
+		}
+
+		motor, msg := parseImportRow(setters, record)
+		if msg != "" {
+			failures = append(failures, importRowError{Line: line, SerialNo: motor.SerialNo, Error: msg})
+			continue
+		}
+		if err := applyWarrantyPolicy(r.Context(), &motor); err != nil {
+			dbError(w, r, "Error looking up warranty policy: "+err.Error())
+			return
+		}
+
+		// As in registerMotor, catch older records that only differ in case
+		// or spacing
+		existing, err := findSimilarMotor(r.Context(), tx, motor.SerialNo)
+		if err == nil {
+			failures = append(failures, importRowError{Line: line, SerialNo: motor.SerialNo,
+				Error: fmt.Sprintf("a motor with a matching serial number %q already exists", existing.SerialNo)})
+			continue
+		}
+		if err != ErrNotFound {
+			dbError(w, r, "Error importing data")
+			return
+		}
+
+		// A savepoint per row lets a failed insert be skipped without
+		// aborting the transaction
+		if _, err := tx.ExecContext(r.Context(), "SAVEPOINT import_row"); err != nil {
+			dbError(w, r, "Error importing data")
+			return
+		}
+		stored, err := insertMotor(r.Context(), tx, motor)
+		if err == nil {
+			err = writeAudit(r.Context(), tx, "register", stored)
+		}
+		if isUniqueViolation(err) {
+			if _, err := tx.ExecContext(r.Context(), "ROLLBACK TO SAVEPOINT import_row"); err != nil {
+				dbError(w, r, "Error importing data")
+				return
+			}
+			failures = append(failures, importRowError{Line: line, SerialNo: motor.SerialNo, Error: "serial number already exists"})
+			continue
+		}
+		if err == nil {
+			_, err = tx.ExecContext(r.Context(), "RELEASE SAVEPOINT import_row")
+		}
+		if err != nil {
+			dbError(w, r, "Error importing data")
+			return
+		}
+		inserted++
+	}
+
+	report := map[string]interface{}{"inserted": inserted, "failed": len(failures), "errors": failures}
+	w.Header().Set("Content-Type", "application/json")
+	if strict && len(failures) > 0 {
+		report["inserted"] = 0
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		dbError(w, r, "Error importing data")
+		return
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseImportRow builds a motor from one CSV record, returning why it can't
+// be imported if it's invalid
+func parseImportRow(setters []func(*Motor, string) error, record []string) (Motor, string) {
+	var motor Motor
+	for i, v := range record {
+		if setters[i] == nil {
+			continue
+		}
+		if err := setters[i](&motor, strings.TrimSpace(v)); err != nil {
+			return motor, err.Error()
+		}
+	}
+	normalizeMotor(&motor)
+	if err := validateMotor(motor); err != nil {
+		return motor, err.Error()
+	}
+	return motor, ""
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestParseImportRow(t *testing.T) {
+	header := []string{"serial_no", "motor_model", "rpm", "phase", "party_name", "dispatch_date", "hp_kw"}
+	setters := make([]func(*Motor, string) error, len(header))
+	for i, name := range header {
+		setters[i] = importSetters[name]
+	}
+
+	tests := []struct {
+		name    string
+		record  []string
+		wantErr string
+	}{
+		{"valid", []string{" sn1 ", "M1", "1440", "three", "Acme", "10/01/2024", "5 HP"}, ""},
+		{"bad rpm", []string{"SN1", "M1", "fast", "three", "Acme", "2024-01-10", ""}, "rpm must be a whole number"},
+		{"missing model", []string{"SN1", "", "1440", "three", "Acme", "2024-01-10", ""}, "motor_model is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			motor, msg := parseImportRow(setters, tt.record)
+			if msg != tt.wantErr {
+				t.Fatalf("error = %q, want %q", msg, tt.wantErr)
+			}
+			if msg == "" && (motor.SerialNo != "SN1" || motor.DispatchDate != "2024-01-10" || motor.PowerKW == nil) {
+				t.Errorf("motor = %+v, want it normalized", motor)
+			}
+		})
+	}
+}
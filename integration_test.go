@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newTestServer connects to the database in TEST_DB_URL, applies migrations
+// and serves the full router. The tests are skipped when no DSN is set.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_URL")
+	if dsn == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("opening test database: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if err := runMigrations(context.Background(), conn); err != nil {
+		t.Fatalf("applying migrations: %v", err)
+	}
+
+	prev := db
+	db = conn
+	t.Cleanup(func() { db = prev })
+	t.Setenv("JWT_SECRET", "integration-test-secret")
+
+	srv := httptest.NewServer(newRouter(&server{store: newPostgresStore(conn)}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testToken signs a token the server accepts for writes
+func testToken(t *testing.T) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "integration-test"}).
+		SignedString([]byte(os.Getenv("JWT_SECRET")))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func postMotor(t *testing.T, srv *httptest.Server, motor Motor) *http.Response {
+	t.Helper()
+	return postMotorAt(t, srv, "", motor)
+}
+
+// postMotorAt registers motor under the API prefix, "" for the deprecated
+// unprefixed routes
+func postMotorAt(t *testing.T, srv *httptest.Server, prefix string, motor Motor) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(motor)
+	req, _ := http.NewRequest("POST", srv.URL+prefix+"/register", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s/register: %v", prefix, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// testMotor returns a valid motor whose serial won't collide with earlier runs
+func testMotor() Motor {
+	return Motor{
+		SerialNo:     fmt.Sprintf("IT-%d", time.Now().UnixNano()),
+		MotorModel:   "IT-MODEL",
+		RPM:          1440,
+		Phase:        "three",
+		PartyName:    "Integration Test Party",
+		DispatchDate: "2024-01-15",
+	}
+}
+
+func TestIntegrationRegisterAndFetch(t *testing.T) {
+	testRegisterAndFetch(t, "")
+}
+
+func TestIntegrationRegisterAndFetchV1(t *testing.T) {
+	testRegisterAndFetch(t, "/v1")
+}
+
+// testRegisterAndFetch registers a motor and fetches it back under prefix
+func testRegisterAndFetch(t *testing.T, prefix string) {
+	srv := newTestServer(t)
+	motor := testMotor()
+
+	resp := postMotorAt(t, srv, prefix, motor)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST %s/register: status = %d, want %d", prefix, resp.StatusCode, http.StatusCreated)
+	}
+
+	resp, err := http.Get(srv.URL + prefix + "/fetch?serial_no=" + motor.SerialNo)
+	if err != nil {
+		t.Fatalf("GET %s/fetch: %v", prefix, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s/fetch: status = %d, want %d", prefix, resp.StatusCode, http.StatusOK)
+	}
+
+	var page struct {
+		Data  []Motor `json:"data"`
+		Total int     `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decoding fetch response: %v", err)
+	}
+	if page.Total != 1 || len(page.Data) != 1 {
+		t.Fatalf("fetch returned %d of %d motors, want 1", len(page.Data), page.Total)
+	}
+	got := page.Data[0]
+	if got.SerialNo != motor.SerialNo || got.PartyName != motor.PartyName || got.WarrantyEndDate != "2025-01-15" {
+		t.Errorf("fetched motor = %+v", got)
+	}
+}
+
+func TestIntegrationRegisterDuplicateSerial(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first POST /register: status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusConflict {
+		t.Errorf("second POST /register: status = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+func TestIntegrationFetchNoParams(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + "/fetch")
+	if err != nil {
+		t.Fatalf("GET /fetch: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET /fetch: status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// The fetch filters can be answered from an index. Sequential scans are
+// turned off so the planner picks an index whenever one applies, however
+// small the test table is; without the index the plan is a Seq Scan.
+func TestIntegrationFetchFiltersUseIndexes(t *testing.T) {
+	newTestServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		where, index string
+	}{
+		{"serial_no = $1", "motors_serial_no_idx"},
+		{"serial_no = $1 AND deleted_at IS NULL", "motors_live_serial_no_key"},
+		{"party_name = $1", "motors_party_name_idx"},
+		{"dispatch_date >= $1", "motors_dispatch_date_idx"},
+		{"lr_or_eway_bill = $1", "motors_lr_or_eway_bill_idx"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.index, func(t *testing.T) {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				t.Fatalf("BeginTx: %v", err)
+			}
+			defer tx.Rollback()
+			if _, err := tx.ExecContext(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+				t.Fatalf("SET enable_seqscan: %v", err)
+			}
+
+			var plan string
+			err = tx.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) SELECT id FROM motors WHERE "+tt.where, "x").Scan(&plan)
+			if err != nil {
+				t.Fatalf("EXPLAIN: %v", err)
+			}
+			if !strings.Contains(plan, `"Index Name": "`+tt.index+`"`) {
+				t.Errorf("plan for %q doesn't use %s:\n%s", tt.where, tt.index, plan)
+			}
+		})
+	}
+}
+
+func TestIntegrationReadyz(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, path := range []string{"/livez", "/readyz"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d once migrations are applied", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+}
+
+// Rows written outside the API can hold NULLs in the columns the original
+// schema left nullable, and must still be fetchable
+func TestIntegrationFetchNullColumns(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+	_, err := db.Exec(`INSERT INTO motors (serial_no, motor_model, rpm, phase, party_name, dispatch_date)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		motor.SerialNo, motor.MotorModel, motor.RPM, motor.Phase, motor.PartyName, motor.DispatchDate)
+	if err != nil {
+		t.Fatalf("inserting motor: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/fetch?serial_no=" + motor.SerialNo)
+	if err != nil {
+		t.Fatalf("GET /fetch: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /fetch: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var page struct {
+		Data []Motor `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		t.Fatalf("decoding fetch response: %v", err)
+	}
+	if len(page.Data) != 1 || page.Data[0].Remarks != "" || page.Data[0].WarrantyEndDate != "" {
+		t.Errorf("fetched motors = %+v", page.Data)
+	}
+}
+
+// A serial frees up once its motor is deleted, so a motor deleted by
+// mistake can be registered again
+func TestIntegrationReregisterDeleted(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /register: status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	req, _ := http.NewRequest("DELETE", srv.URL+"/v1/motor/"+motor.SerialNo, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /motor: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("DELETE /motor: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusCreated {
+		t.Errorf("POST /register after delete: status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusConflict {
+		t.Errorf("second POST /register: status = %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+}
+
+// A dispatch date that looks right but doesn't exist is left out of
+// /report rather than failing it
+func TestIntegrationReportSkipsImpossibleDates(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+	_, err := db.Exec(`INSERT INTO motors (serial_no, motor_model, rpm, phase, party_name, dispatch_date)
+        VALUES ($1, $2, $3, $4, $5, '2024-02-30')`,
+		motor.SerialNo, motor.MotorModel, motor.RPM, motor.Phase, motor.PartyName)
+	if err != nil {
+		t.Fatalf("inserting motor: %v", err)
+	}
+
+	resp, err := http.Get(srv.URL + "/v1/report?group_by=party")
+	if err != nil {
+		t.Fatalf("GET /report: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET /report: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+}
+
+// A CSV import runs on IMPORT_TIMEOUT, so one that outlasts REQUEST_TIMEOUT
+// still commits
+func TestIntegrationImportOutlastsRequestTimeout(t *testing.T) {
+	newTestServer(t)
+	srv := httptest.NewServer(timeoutRequests(time.Millisecond, time.Minute,
+		newRouter(&server{store: newPostgresStore(db)})))
+	defer srv.Close()
+
+	prefix := testMotor().SerialNo
+	var csv strings.Builder
+	csv.WriteString("serial_no,motor_model,rpm,phase,dispatch_date\n")
+	const rows = 500
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&csv, "%s-%d,IT-MODEL,1440,three,2024-01-15\n", prefix, i)
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "motors.csv")
+	part.Write([]byte(csv.String()))
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/import/csv", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /import/csv: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST /import/csv: status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	var stored int
+	if err := db.QueryRow("SELECT count(*) FROM motors WHERE serial_no LIKE $1", prefix+"-%").Scan(&stored); err != nil {
+		t.Fatalf("counting imported motors: %v", err)
+	}
+	if stored != rows {
+		t.Errorf("imported %d motors, want %d", stored, rows)
+	}
+}
+
+// A re-registered serial's history starts over, leaving out the deleted
+// motor's entries
+func TestIntegrationHistoryAfterReregister(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /register: status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	req, _ := http.NewRequest("DELETE", srv.URL+"/v1/motor/"+motor.SerialNo, nil)
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /motor: %v", err)
+	}
+	resp.Body.Close()
+	if resp := postMotor(t, srv, motor); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /register after delete: status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	resp, err = http.Get(srv.URL + "/v1/motor/" + motor.SerialNo + "/history")
+	if err != nil {
+		t.Fatalf("GET /history: %v", err)
+	}
+	defer resp.Body.Close()
+	var history struct {
+		Data []historyEntry `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatalf("decoding history: %v", err)
+	}
+	if len(history.Data) != 1 || history.Data[0].Action != "register" {
+		t.Errorf("history = %+v, want just the new motor's register entry", history.Data)
+	}
+}
+
+// CSV imports skip rows whose serial only differs in case or spacing from
+// an older record's
+func TestIntegrationImportNearDuplicate(t *testing.T) {
+	srv := newTestServer(t)
+	motor := testMotor()
+	legacy := strings.ToLower(motor.SerialNo) + " "
+	_, err := db.Exec(`INSERT INTO motors (serial_no, motor_model, rpm, phase, party_name, dispatch_date)
+        VALUES ($1, $2, $3, $4, $5, $6)`,
+		legacy, motor.MotorModel, motor.RPM, motor.Phase, motor.PartyName, motor.DispatchDate)
+	if err != nil {
+		t.Fatalf("inserting motor: %v", err)
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", "motors.csv")
+	fmt.Fprintf(part, "serial_no,motor_model,rpm,phase,dispatch_date\n%s,IT-MODEL,1440,three,2024-01-15\n", motor.SerialNo)
+	mw.Close()
+
+	req, _ := http.NewRequest("POST", srv.URL+"/v1/import/csv", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /import/csv: %v", err)
+	}
+	defer resp.Body.Close()
+	var report struct {
+		Inserted int              `json:"inserted"`
+		Errors   []importRowError `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding report: %v", err)
+	}
+	if report.Inserted != 0 || len(report.Errors) != 1 || report.Errors[0].Line != 2 {
+		t.Errorf("report = %+v, want line 2 rejected as a near-duplicate", report)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// lookupMotor is GetBySerial for read-only handlers. Concurrent lookups of
+// the same serial, as when a shared QR code is scanned by many people at
+// once, share a single store call and its result. Each caller still stops
+// waiting when its own context is done.
+func (s *server) lookupMotor(ctx context.Context, serial string) (Motor, error) {
+	ch := s.lookups.DoChan(serial, func() (interface{}, error) {
+		// The call outlives any one caller giving up, so it gets its own
+		// deadline rather than the first caller's context
+		ctx := context.WithoutCancel(ctx)
+		if s.requestTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+			defer cancel()
+		}
+		return s.store.GetBySerial(ctx, serial)
+	})
+	select {
+	case <-ctx.Done():
+		return Motor{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return Motor{}, res.Err
+		}
+		return res.Val.(Motor), nil
+	}
+}
+
+// identifierFilters are the ways /lookup reads q, in the order it tries
+// them: an exact serial, a party name containing q, then an exact LR or
+// e-way bill number
+var identifierFilters = []struct {
+	name   string
+	filter func(q string) MotorFilter
+}{
+	{"serial_no", func(q string) MotorFilter { return MotorFilter{SerialNo: normalizeSerial(q)} }},
+	{"party_name", func(q string) MotorFilter { return MotorFilter{PartyNames: []string{q}, PartySearch: true} }},
+	{"lr_eway_bill", func(q string) MotorFilter { return MotorFilter{LREwayBill: q} }},
+}
+
+// lookupAnyIdentifier serves /lookup?q=, for a single search box taking
+// whichever identifier a customer has to hand. It returns the motors for the
+// first identifier q matches, with matched_by naming it.
+func (s *server) lookupAnyIdentifier(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, id := range identifierFilters {
+		found, total, err := s.store.Fetch(r.Context(), MotorQuery{Filter: id.filter(q), Sort: order, Limit: limit, Offset: offset})
+		if err != nil {
+			dbError(w, r, "Error looking up motors: "+err.Error())
+			return
+		}
+		if total == 0 {
+			continue
+		}
+
+		motors := make([]map[string]interface{}, 0, len(found))
+		for _, motor := range found {
+			motors = append(motors, motorResponse(motor))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"matched_by": id.name,
+			"data":       motors,
+			"total":      total,
+			"limit":      limit,
+			"offset":     offset,
+			"truncated":  markTruncated(w, offset+len(found) < total),
+		})
+		return
+	}
+	writeJSONError(w, http.StatusNotFound, "No motors found")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowStore blocks GetBySerial until release is closed, counting the calls
+type slowStore struct {
+	MotorStore
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *slowStore) GetBySerial(ctx context.Context, serial string) (Motor, error) {
+	s.calls.Add(1)
+	<-s.release
+	return s.MotorStore.GetBySerial(ctx, serial)
+}
+
+func TestLookupMotorSharesConcurrentCalls(t *testing.T) {
+	store := &slowStore{MotorStore: seedMemoryStore(t), release: make(chan struct{})}
+	s := &server{store: store}
+
+	const callers = 10
+	var started, done sync.WaitGroup
+	started.Add(callers)
+	done.Add(callers)
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer done.Done()
+			started.Done()
+			motor, err := s.lookupMotor(context.Background(), "SN1")
+			if err == nil && motor.SerialNo != "SN1" {
+				t.Errorf("got motor %q, want SN1", motor.SerialNo)
+			}
+			errs <- err
+		}()
+	}
+	started.Wait()
+	// Give every caller time to join the call in flight
+	for store.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	done.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("lookupMotor: %v", err)
+		}
+	}
+	if n := store.calls.Load(); n >= callers {
+		t.Errorf("store was called %d times for %d concurrent lookups", n, callers)
+	}
+}
+
+func TestLookupAnyIdentifier(t *testing.T) {
+	s := &server{store: seedMemoryStore(t)}
+	tests := []struct {
+		q, matchedBy, serials string
+		status                int
+	}{
+		{"SN1", "serial_no", "SN1", http.StatusOK},
+		{"acme", "party_name", "SN2,SN1", http.StatusOK},
+		{"LR-1", "lr_eway_bill", "SN2,SN3", http.StatusOK},
+		{"nothing", "", "", http.StatusNotFound},
+		{" ", "", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.lookupAnyIdentifier(rec, httptest.NewRequest("GET", "/lookup?q="+url.QueryEscape(tt.q), nil))
+		if rec.Code != tt.status {
+			t.Errorf("q=%q: status = %d, want %d", tt.q, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var resp struct {
+			MatchedBy string  `json:"matched_by"`
+			Data      []Motor `json:"data"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("q=%q: decoding response: %v", tt.q, err)
+		}
+		if resp.MatchedBy != tt.matchedBy || serials(resp.Data) != tt.serials {
+			t.Errorf("q=%q: matched_by %q with %s, want %q with %s",
+				tt.q, resp.MatchedBy, serials(resp.Data), tt.matchedBy, tt.serials)
+		}
+	}
+}
+
+// A caller gives up at its own deadline even while the shared call is still
+// waiting on the store
+func TestLookupMotorHonoursCallerDeadline(t *testing.T) {
+	store := &slowStore{MotorStore: seedMemoryStore(t), release: make(chan struct{})}
+	defer close(store.release)
+	s := &server{store: store, requestTimeout: time.Minute}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.lookupMotor(ctx, "SN1"); err != context.DeadlineExceeded {
+		t.Errorf("lookupMotor: err = %v, want context.DeadlineExceeded", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("lookupMotor returned after %v, well past the caller's deadline", waited)
+	}
+}
@@ -1,172 +1,1085 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
+	"io/fs"
 	"log"
+	"log/slog"
 	"net/http"
+	"net/mail"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 )
 
 type Motor struct {
-	SerialNo        string `json:"serial_no"`
-	MotorModel      string `json:"motor_model"`
-	RPM             int    `json:"rpm"`
-	Phase           string `json:"phase"`
-	PartyName       string `json:"party_name"`
-	DispatchDate    string `json:"dispatch_date"`
-	TransportAgency string `json:"transport_agency"`
-	LREwayBill      string `json:"lr_eway_bill"`
-	TestCertificate string `json:"test_certificate"`
-	PartyAddress    string `json:"party_address"`
-	HPKW            string `json:"hp_kw"`
-	Remarks         string `json:"remarks"`
+	ID                int64     `json:"id"`
+	SerialNo          string    `json:"serial_no"`
+	MotorModel        string    `json:"motor_model"`
+	RPM               int       `json:"rpm"`
+	Phase             string    `json:"phase"`
+	PartyName         string    `json:"party_name"`
+	DispatchDate      string    `json:"dispatch_date"`
+	TransportAgency   string    `json:"transport_agency"`
+	LREwayBill        string    `json:"lr_eway_bill"`
+	TestCertificate   string    `json:"test_certificate"`
+	PartyAddress      string    `json:"party_address"`
+	HPKW              string    `json:"hp_kw"`
+	PowerKW           *float64  `json:"power_kw"` // HPKW in kW, set by normalizeMotor
+	Remarks           string    `json:"remarks"`
+	WarrantyStartDate string    `json:"warranty_start_date"`
+	WarrantyEndDate   string    `json:"warranty_end_date"`
+	PartyEmail        string    `json:"party_email"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Version           int64     `json:"version"`
+}
+
+// Warranty period applied when the client doesn't send an end date and no
+// warranty policy matches
+const defaultWarrantyMonths = 12
+
+// fillWarrantyDates defaults the warranty start to the dispatch date and the
+// end to the start plus months. Unparseable dates are left as-is.
+func fillWarrantyDates(m *Motor, months int) {
+	if m.WarrantyStartDate == "" {
+		m.WarrantyStartDate = m.DispatchDate
+	}
+	if m.WarrantyEndDate != "" {
+		return
+	}
+	start, err := time.Parse("2006-01-02", m.WarrantyStartDate)
+	if err != nil {
+		return
+	}
+	m.WarrantyEndDate = start.AddDate(0, months, 0).Format("2006-01-02")
+}
+
+// computeWarrantyStatus reports whether a warranty ending on endDate is
+// still active today. Missing or malformed dates yield "unknown".
+func computeWarrantyStatus(endDate string) string {
+	end, err := time.Parse("2006-01-02", strings.TrimSpace(endDate))
+	if err != nil {
+		return "unknown"
+	}
+	// The warranty covers the whole of its last day
+	if time.Now().Before(end.AddDate(0, 0, 1)) {
+		return "active"
+	}
+	return "expired"
+}
+
+// Layouts accepted for incoming dispatch dates, tried in order. Numeric dates
+// are read day first, as they're written on Indian dispatch documents.
+var dispatchDateLayouts = []string{
+	"2006-01-02",
+	"02/01/2006",
+	"2/1/2006",
+	"02-01-2006",
+	"02.01.2006",
+	"2006/01/02",
+	"02 Jan 2006",
+	"2 Jan 2006",
+	"Jan 2, 2006",
+}
+
+// normalizeDate parses s with any of the dispatchDateLayouts and returns it
+// in YYYY-MM-DD form
+func normalizeDate(s string) (string, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range dispatchDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format("2006-01-02"), true
+		}
+	}
+	return s, false
+}
+
+// normalizeSerial puts a serial number in its stored form, so that
+// "abc123 " and "ABC123" are the same motor
+func normalizeSerial(serial string) string {
+	return strings.ToUpper(strings.TrimSpace(serial))
+}
+
+// serialParam reads the serial_no path param in its stored form
+func serialParam(r *http.Request) string {
+	return normalizeSerial(mux.Vars(r)["serial_no"])
+}
+
+// normalizeMotor rewrites client input into the form it's stored in. Values
+// it can't make sense of are left for validateMotor to reject.
+func normalizeMotor(m *Motor) {
+	m.SerialNo = normalizeSerial(m.SerialNo)
+	m.PartyEmail = strings.TrimSpace(m.PartyEmail)
+	m.Phase = normalizePhase(m.Phase)
+	if date, ok := normalizeDate(m.DispatchDate); ok {
+		m.DispatchDate = date
+	}
+	m.PowerKW = motorPowerKW(*m)
+}
+
+// validateMotor checks the fields a record needs before it can be stored,
+// reporting the first problem found
+func validateMotor(m Motor) error {
+	if problems := motorProblems(m); len(problems) > 0 {
+		return errors.New(problems[0])
+	}
+	return nil
+}
+
+// motorProblems lists everything wrong with m that validateMotor checks
+func motorProblems(m Motor) []string {
+	var problems []string
+	if strings.TrimSpace(m.SerialNo) == "" {
+		problems = append(problems, "serial_no is required")
+	}
+	if strings.TrimSpace(m.MotorModel) == "" {
+		problems = append(problems, "motor_model is required")
+	}
+	if m.RPM <= 0 {
+		problems = append(problems, "rpm must be greater than 0")
+	}
+	if m.Phase != "single" && m.Phase != "three" {
+		problems = append(problems, `phase must be "single" or "three"`)
+	}
+	if m.PartyEmail != "" && !isBareEmail(m.PartyEmail) {
+		problems = append(problems, "party_email must be a valid email address")
+	}
+	if _, err := time.Parse("2006-01-02", m.DispatchDate); err != nil {
+		problems = append(problems, "dispatch_date must be a valid date, e.g. YYYY-MM-DD or DD/MM/YYYY")
+	}
+	if strings.TrimSpace(m.HPKW) != "" {
+		if _, err := parsePowerKW(m.HPKW); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// isBareEmail reports whether s is an email address with no display name,
+// since party_email is used as the SMTP recipient
+func isBareEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// isUniqueViolation reports whether err is a Postgres unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// motorColumns lists the client-writable motors columns in insert order.
+// Every query that inserts full records should use it.
+const motorColumns = `serial_no, motor_model, rpm, phase, party_name, dispatch_date, 
+              transport_agency, lr_or_eway_bill, test_certificate, party_address, hp_kw, remarks, 
+              warranty_start_date, warranty_end_date, party_email, power_kw`
+
+// motorSelectColumns adds the generated columns to motorColumns in the order
+// scanMotor reads them. Every query that returns full records should use it.
+const motorSelectColumns = `id, ` + motorColumns + `, created_at, updated_at, version`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanMotor reads a row selected with motorSelectColumns. The columns the
+// original schema left nullable come back as "" when NULL, as rows imported
+// before the API wrote them often have NULLs there.
+func scanMotor(row rowScanner) (Motor, error) {
+	var motor Motor
+	var transportAgency, lrEwayBill, testCertificate, partyAddress, hpKW, remarks sql.NullString
+	var warrantyStart, warrantyEnd sql.NullString
+	err := row.Scan(&motor.ID, &motor.SerialNo, &motor.MotorModel, &motor.RPM, &motor.Phase, &motor.PartyName,
+		&motor.DispatchDate, &transportAgency, &lrEwayBill, &testCertificate,
+		&partyAddress, &hpKW, &remarks, &warrantyStart, &warrantyEnd,
+		&motor.PartyEmail, &motor.PowerKW, &motor.CreatedAt, &motor.UpdatedAt, &motor.Version)
+	motor.TransportAgency = transportAgency.String
+	motor.LREwayBill = lrEwayBill.String
+	motor.TestCertificate = testCertificate.String
+	motor.PartyAddress = partyAddress.String
+	motor.HPKW = hpKW.String
+	motor.Remarks = remarks.String
+	motor.WarrantyStartDate = warrantyStart.String
+	motor.WarrantyEndDate = warrantyEnd.String
+	return motor, err
+}
+
+// motorResponse is the JSON shape returned for a motor, including fields
+// derived at read time
+func motorResponse(motor Motor) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  motor.ID,
+		"serial_no":           motor.SerialNo,
+		"motor_model":         motor.MotorModel,
+		"rpm":                 motor.RPM,
+		"phase":               motor.Phase,
+		"party_name":          motor.PartyName,
+		"dispatch_date":       motor.DispatchDate,
+		"transport_agency":    motor.TransportAgency,
+		"lr_eway_bill":        motor.LREwayBill,
+		"test_certificate":    motor.TestCertificate,
+		"party_address":       motor.PartyAddress,
+		"party_email":         motor.PartyEmail,
+		"hp_kw":               motor.HPKW,
+		"power_kw":            motor.PowerKW,
+		"remarks":             motor.Remarks,
+		"warranty_start_date": motor.WarrantyStartDate,
+		"warranty_end_date":   motor.WarrantyEndDate,
+		"warranty_status":     computeWarrantyStatus(motor.WarrantyEndDate),
+		"created_at":          motor.CreatedAt.Format(time.RFC3339),
+		"updated_at":          motor.UpdatedAt.Format(time.RFC3339),
+		"version":             motor.Version,
+	}
+}
+
+// writeJSONError sends the error envelope every failed request gets, so
+// clients never have to branch on content type
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": message, "status": status})
 }
 
 var db *sql.DB
 
 func initDB() {
+	// A .env file is optional; in production the real environment is used
 	err := godotenv.Load()
-	if err != nil {
-		log.Fatal("Error loading .env file")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file: ", err)
 	}
+	requireEnv("DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME")
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+	// search_path is sent as a session setting on every connection, so
+	// unqualified table names resolve in DB_SCHEMA alone and never fall
+	// through to another environment's tables
+	schema := envSchema()
+	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable search_path=%s",
 		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+		os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"), schema)
 
-	var errDB error
-	db, errDB = sql.Open("postgres", connStr)
-	if errDB != nil {
-		log.Fatal(errDB)
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		log.Fatal(err)
 	}
+	db = sql.OpenDB(loggingConnector{connector, time.Duration(envInt("SLOW_QUERY_MS", 500)) * time.Millisecond})
 
-	err = db.Ping()
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	// Bound the pool so load can't exhaust Postgres or hold stale connections
+	db.SetMaxOpenConns(envInt("DB_MAX_OPEN", 25))
+	db.SetMaxIdleConns(envInt("DB_MAX_IDLE", 5))
+	db.SetConnMaxLifetime(envDuration("DB_CONN_LIFETIME", 5*time.Minute))
+
+	// Postgres may still be starting, as when both come up under docker-compose
+	ctx, cancel := context.WithTimeout(context.Background(), envDuration("DB_STARTUP_TIMEOUT", 30*time.Second))
+	defer cancel()
+	if err := waitForDB(ctx, db.PingContext); err != nil {
+		log.Fatal("Failed to connect to database: ", err)
 	}
-	fmt.Println("Connected to PostgreSQL")
+	// Migrations can only create tables in a schema that exists. public
+	// always does, and creating a schema needs a privilege the app's role
+	// may not have.
+	if schema != "public" {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS "+pq.QuoteIdentifier(schema)); err != nil {
+			log.Fatal("Failed to create schema ", schema, ": ", err)
+		}
+	}
+	slog.Info("Connected to PostgreSQL", "schema", schema)
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertMotor writes a single motor using q, which may be a transaction, and
+// returns the record as stored
+func insertMotor(ctx context.Context, q querier, motor Motor) (Motor, error) {
+	query := `INSERT INTO motors (` + motorColumns + `) 
+              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) 
+              RETURNING ` + motorSelectColumns
+
+	return scanMotor(q.QueryRowContext(ctx, query, motor.SerialNo, motor.MotorModel, motor.RPM, motor.Phase,
+		motor.PartyName, motor.DispatchDate, motor.TransportAgency, motor.LREwayBill, motor.TestCertificate,
+		motor.PartyAddress, motor.HPKW, motor.Remarks, motor.WarrantyStartDate, motor.WarrantyEndDate,
+		motor.PartyEmail, motor.PowerKW))
 }
 
-func registerMotor(w http.ResponseWriter, r *http.Request) {
+// maxBodyBytes caps the size of a register request body; MAX_BODY_BYTES
+// overrides it at startup
+var maxBodyBytes int64 = 1 << 20
+
+func (s *server) registerMotor(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	// A typo'd key would otherwise be dropped silently and leave its field empty
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
 	var motor Motor
-	err := json.NewDecoder(r.Body).Decode(&motor)
+	err := decoder.Decode(&motor)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		writeJSONError(w, http.StatusBadRequest, strings.TrimPrefix(err.Error(), "json: "))
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	normalizeMotor(&motor)
+	if r.URL.Query().Get("dry_run") == "true" {
+		s.checkRegistration(w, r, motor)
+		return
+	}
+	if err := validateMotor(motor); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := applyWarrantyPolicy(r.Context(), &motor); err != nil {
+		dbError(w, r, "Error looking up warranty policy: "+err.Error())
 		return
 	}
 
-	query := `INSERT INTO motors (serial_no, motor_model, rpm, phase, party_name, dispatch_date, 
-              transport_agency, lr_or_eway_bill, test_certificate, party_address, hp_kw, remarks) 
-              VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
-
-	_, err = db.Exec(query, motor.SerialNo, motor.MotorModel, motor.RPM, motor.Phase, motor.PartyName,
-		motor.DispatchDate, motor.TransportAgency, motor.LREwayBill, motor.TestCertificate,
-		motor.PartyAddress, motor.HPKW, motor.Remarks)
+	// Older records may predate serial normalization, so look for one that
+	// only differs in case or spacing before inserting
+	existing, err := s.store.FindSimilar(r.Context(), motor.SerialNo)
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":    "a motor with a matching serial number already exists",
+			"status":   http.StatusConflict,
+			"conflict": motorResponse(existing),
+		})
+		return
+	}
+	if err != ErrNotFound {
+		dbError(w, r, "Error inserting data")
+		return
+	}
 
+	stored, err := s.store.Register(r.Context(), motor)
+	if err == ErrDuplicateSerial {
+		writeJSONError(w, http.StatusConflict, "serial number already exists")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error inserting data", http.StatusInternalServerError)
+		dbError(w, r, "Error inserting data")
 		return
 	}
+	if s.mail != nil {
+		s.mail.notifyRegistered(stored, requestIDFrom(r.Context()))
+	}
+	if s.hooks != nil {
+		s.hooks.dispatch("motor.registered", motorResponse(stored), requestIDFrom(r.Context()))
+	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"message": "Motor registered successfully"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": "Motor registered successfully",
+		"data":    motorResponse(stored),
+	})
 }
 
-func fetchMotor(w http.ResponseWriter, r *http.Request) {
-	// Get query params
-	serial := r.URL.Query().Get("serial_no")
-	serial = strings.TrimSpace(serial)
-	party := r.URL.Query().Get("party_name")
-	party = strings.TrimSpace(party)
+// checkRegistration answers a dry run of registerMotor: it reports every
+// problem that would stop motor being registered, including a clashing
+// serial number, without storing anything
+func (s *server) checkRegistration(w http.ResponseWriter, r *http.Request, motor Motor) {
+	problems := motorProblems(motor)
+	if strings.TrimSpace(motor.SerialNo) != "" {
+		_, err := s.store.FindSimilar(r.Context(), motor.SerialNo)
+		if err == nil {
+			problems = append(problems, "serial number already exists")
+		} else if err != ErrNotFound {
+			dbError(w, r, "Error checking serial number")
+			return
+		}
+	}
 
-	// Prepare SQL query based on available parameters
-	var rows *sql.Rows
-	var err error
+	w.Header().Set("Content-Type", "application/json")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]interface{}{"valid": false, "problems": problems})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]bool{"valid": true})
+}
 
-	// Handle queries with variable names
-	var query string
-	if serial != "" && party != "" {
-		query = "SELECT * FROM motors WHERE serial_no = $1 AND party_name = $2"
-		rows, err = db.Query(query, serial, party)
-	} else if party == "" {
-		query = "SELECT * FROM motors WHERE serial_no = $1"
-		rows, err = db.Query(query, serial)
-	} else if serial == "" {
-		query = "SELECT * FROM motors WHERE party_name = $1"
-		rows, err = db.Query(query, party)
-	} else {
-		http.Error(w, "No valid query parameters provided", http.StatusBadRequest)
+// registerMotorsBulk stores a batch of motors all at once. Nothing is stored
+// unless every motor in the batch is valid and inserts cleanly.
+func (s *server) registerMotorsBulk(w http.ResponseWriter, r *http.Request) {
+	var motors []Motor
+	err := json.NewDecoder(r.Body).Decode(&motors)
+	if err != nil || len(motors) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
-	// Query the database
+	// failure reports the first motor that stopped the batch
+	failure := func(status, index int, msg string) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"index": index, "error": msg, "status": status})
+	}
+
+	for i := range motors {
+		normalizeMotor(&motors[i])
+		if err := validateMotor(motors[i]); err != nil {
+			failure(http.StatusBadRequest, i, err.Error())
+			return
+		}
+		if err := applyWarrantyPolicy(r.Context(), &motors[i]); err != nil {
+			dbError(w, r, "Error looking up warranty policy: "+err.Error())
+			return
+		}
+		// As in registerMotor, catch older records that only differ in case
+		// or spacing
+		existing, err := s.store.FindSimilar(r.Context(), motors[i].SerialNo)
+		if err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"index":    i,
+				"error":    "a motor with a matching serial number already exists",
+				"status":   http.StatusConflict,
+				"conflict": motorResponse(existing),
+			})
+			return
+		}
+		if err != ErrNotFound {
+			dbError(w, r, "Error inserting data")
+			return
+		}
+	}
+
+	stored, err := s.store.RegisterBatch(r.Context(), motors)
+	var batchErr *BatchError
+	if errors.As(err, &batchErr) {
+		if batchErr.Err == ErrDuplicateSerial {
+			failure(http.StatusConflict, batchErr.Index, "serial number already exists")
+			return
+		}
+		dbErrorsTotal.WithLabelValues(routeLabel(r)).Inc()
+		failure(http.StatusInternalServerError, batchErr.Index, "Error inserting data")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error fetching motors: "+err.Error(), http.StatusInternalServerError)
+		dbError(w, r, "Error inserting data")
 		return
 	}
 
-	var motors []map[string]interface{}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"inserted": len(stored)})
+}
 
-	// Iterate over the rows and append results
-	for rows.Next() {
-		var motor Motor
-		err := rows.Scan(&motor.SerialNo, &motor.MotorModel, &motor.RPM, &motor.Phase, &motor.PartyName,
-			&motor.DispatchDate, &motor.TransportAgency, &motor.LREwayBill, &motor.TestCertificate,
-			&motor.PartyAddress, &motor.HPKW, &motor.Remarks)
-		if err != nil {
-			http.Error(w, "Error scanning row: "+err.Error(), http.StatusInternalServerError)
-			return
+const (
+	defaultFetchLimit = 50
+	maxFetchLimit     = 500
+)
+
+// maxResultRows is the most motors any listing returns in one response,
+// paged or not, so no query can build an unbounded reply; MAX_RESULT_ROWS
+// overrides it at startup
+var maxResultRows = 1000
+
+// resultTruncatedHeader is set on listings that left out matching motors,
+// whether past the requested limit or past maxResultRows
+const resultTruncatedHeader = "X-Result-Truncated"
+
+// markTruncated sets resultTruncatedHeader when more motors matched than a
+// listing returned, and passes truncated on for its truncated field
+func markTruncated(w http.ResponseWriter, truncated bool) bool {
+	if truncated {
+		w.Header().Set(resultTruncatedHeader, "true")
+	}
+	return truncated
+}
+
+// parsePagination reads the limit and offset query params, applying the
+// defaults when absent and capping limit at maxFetchLimit and maxResultRows
+func parsePagination(r *http.Request) (int, int, error) {
+	limit, offset := defaultFetchLimit, 0
+	if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("limit must be a non-negative integer")
 		}
-		motors = append(motors, map[string]interface{}{
-			"serial_no":        motor.SerialNo,
-			"motor_model":      motor.MotorModel,
-			"rpm":              motor.RPM,
-			"phase":            motor.Phase,
-			"party_name":       motor.PartyName,
-			"dispatch_date":    motor.DispatchDate,
-			"transport_agency": motor.TransportAgency,
-			"lr_eway_bill":     motor.LREwayBill,
-			"test_certificate": motor.TestCertificate,
-			"party_address":    motor.PartyAddress,
-			"hp_kw":            motor.HPKW,
-			"remarks":          motor.Remarks,
-		})
+		limit = n
+	}
+	if v := strings.TrimSpace(r.URL.Query().Get("offset")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+	limit = min(limit, maxFetchLimit, maxResultRows)
+	return limit, offset, nil
+}
+
+// Columns fetchMotor may sort by. sort_by is checked against this list so it
+// can be put into the query safely.
+var sortableColumns = map[string]bool{
+	"dispatch_date": true,
+	"serial_no":     true,
+	"party_name":    true,
+	"motor_model":   true,
+	"created_at":    true,
+	"updated_at":    true,
+}
+
+// sortOrder is the column and direction motor listings are sorted by
+type sortOrder struct {
+	Column string
+	Desc   bool
+}
+
+// clause renders s as an ORDER BY clause. id breaks ties so pages stay
+// stable.
+func (s sortOrder) clause() string {
+	dir := "ASC"
+	if s.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, id", s.Column, dir)
+}
+
+// parseSort reads the sort_by and order query params, defaulting to the
+// newest dispatches first
+func parseSort(r *http.Request) (sortOrder, error) {
+	sortBy := strings.TrimSpace(r.URL.Query().Get("sort_by"))
+	if sortBy == "" {
+		sortBy = "dispatch_date"
+	}
+	if !sortableColumns[sortBy] {
+		return sortOrder{}, fmt.Errorf("cannot sort by %q", sortBy)
+	}
+
+	switch order := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("order"))); order {
+	case "", "desc":
+		return sortOrder{Column: sortBy, Desc: true}, nil
+	case "asc":
+		return sortOrder{Column: sortBy}, nil
+	default:
+		return sortOrder{}, errors.New(`order must be "asc" or "desc"`)
+	}
+}
+
+var errNoFilters = errors.New("No valid query parameters provided")
+
+// buildFetchQuery assembles the paged SELECT of columns for /fetch. At least
+// one parameterised filter is required; literal conditions such as the
+// deleted_at check don't count, since they never come from the caller.
+func buildFetchQuery(columns []string, filters map[string]interface{}, orderBy string, limit, offset int) (string, []interface{}, error) {
+	hasParams := false
+	for _, arg := range filters {
+		if arg != nil {
+			hasParams = true
+			break
+		}
+	}
+	if !hasParams {
+		return "", nil, errNoFilters
+	}
+
+	where, args := buildWhere(filters)
+	query := fmt.Sprintf("SELECT %s FROM motors %s %s LIMIT $%d OFFSET $%d",
+		strings.Join(columns, ", "), where, orderBy, len(args)+1, len(args)+2)
+	return query, append(args, limit, offset), nil
+}
+
+func (s *server) fetchMotor(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseMotorFilter(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "offset" && mode != "cursor" {
+		writeJSONError(w, http.StatusBadRequest, `mode must be "offset" or "cursor"`)
+		return
+	}
+	if filter.isEmpty() && mode != "cursor" {
+		writeJSONError(w, http.StatusBadRequest, errNoFilters.Error())
+		return
+	}
+	filter.IncludeDeleted, err = includeDeleted(r)
+	if err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if mode == "cursor" {
+		s.fetchMotorCursor(w, r, filter, fields)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := MotorQuery{Filter: filter, Sort: order, Limit: limit, Offset: offset, Fields: fields}
+	found, total, err := s.store.Fetch(r.Context(), query)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
 	}
 
 	// Handle no results found
-	if len(motors) == 0 {
-		http.Error(w, "No motors found", http.StatusNotFound)
+	if total == 0 {
+		writeJSONError(w, http.StatusNotFound, "No motors found")
 		return
 	}
 
+	motors := make([]map[string]interface{}, 0, len(found))
+	for _, motor := range found {
+		motors = append(motors, selectFields(motorResponse(motor), fields))
+	}
+
 	// Return the results as JSON
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(motors)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":      motors,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+		"truncated": markTruncated(w, offset+len(found) < total),
+	})
+}
+
+func (s *server) getMotor(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	motor, err := s.lookupMotor(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	etag := motorETag(motor)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(motorResponse(motor))
 }
 
-func main() {
-	initDB()
+// motorETag identifies a stored revision of a motor. Every write bumps the
+// version, which starts over at 1 when a deleted motor's serial is registered
+// again, so the id is what tells the new motor from the deleted one.
+func motorETag(m Motor) string {
+	return fmt.Sprintf(`"%d-%d"`, m.ID, m.Version)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag or is *.
+// Weak validators compare equal to strong ones, as If-None-Match requires.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func getMotorByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	writeMotorLookup(w, r, "id = $1", id)
+}
+
+// liveSerialMatch is the condition for the live motor with serial number $1.
+// Motors stored before registration normalized serials are matched on their
+// normalized serial, as in findSimilar, but only when none matches exactly.
+const liveSerialMatch = `deleted_at IS NULL AND id = COALESCE(
+        (SELECT id FROM motors WHERE serial_no = $1 AND deleted_at IS NULL),
+        (SELECT id FROM motors WHERE upper(trim(serial_no)) = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1))`
+
+// findMotor loads the live motor with the given serial number
+func findMotor(ctx context.Context, q querier, serial string) (Motor, error) {
+	return scanMotor(q.QueryRowContext(ctx,
+		"SELECT "+motorSelectColumns+" FROM motors WHERE "+liveSerialMatch, serial))
+}
+
+// writeMotorLookup responds with the single motor matching where, or 404
+func writeMotorLookup(w http.ResponseWriter, r *http.Request, where string, arg interface{}) {
+	motor, err := scanMotor(db.QueryRowContext(r.Context(),
+		"SELECT "+motorSelectColumns+" FROM motors WHERE deleted_at IS NULL AND "+where, arg))
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(motorResponse(motor))
+}
+
+func countMotors(w http.ResponseWriter, r *http.Request) {
+	party := strings.TrimSpace(r.URL.Query().Get("party_name"))
+
+	// Count everything unless a party was asked for
+	filters := map[string]interface{}{}
+	if party != "" {
+		filters["party_name = $%d"] = party
+	}
+	if err := addDeletedFilter(r, filters); err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	where, args := buildWhere(filters)
+
+	var count int
+	err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM motors "+where, args...).Scan(&count)
+	if err != nil {
+		dbError(w, r, "Error counting motors: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"count": count})
+}
+
+func (s *server) updateMotor(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	// Hold on to the raw body so it can be applied over the stored record
+	var patch json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	// Clients must say which version they edited, so a change someone else
+	// saved in the meantime isn't silently overwritten
+	var sent struct {
+		Version *int64 `json:"version"`
+	}
+	if err := json.Unmarshal(patch, &sent); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	if sent.Version == nil {
+		writeJSONError(w, http.StatusBadRequest, "version is required")
+		return
+	}
+
+	// Load the current record so fields the client didn't send keep their values
+	motor, err := s.store.GetBySerial(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	stored := motor.SerialNo
+	err = json.Unmarshal(patch, &motor)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+	normalizeMotor(&motor)
+	// The serial in the path identifies the record and the id is generated,
+	// so neither can be changed here. The serial is kept as stored, since
+	// older records may not be normalized.
+	motor.SerialNo = stored
+	if err := validateMotor(motor); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := applyWarrantyPolicy(r.Context(), &motor); err != nil {
+		dbError(w, r, "Error looking up warranty policy: "+err.Error())
+		return
+	}
+
+	updated, err := s.store.Update(r.Context(), motor)
+	// Not found here means it was deleted since we loaded it
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err == ErrVersionConflict {
+		writeJSONError(w, http.StatusConflict, "Motor was changed by another request; reload it and try again")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(motorResponse(updated))
+}
+
+func (s *server) deleteMotor(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	err := s.store.Delete(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error deleting data")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Motor deleted"})
+}
+
+// newRouter registers every route on a fresh router, serving motor reads and
+// writes from s. The API lives under /v1; the same routes without the prefix
+// are deprecated aliases kept for existing clients.
+func newRouter(s *server) *mux.Router {
 	r := mux.NewRouter()
+	r.Use(recordMetrics)
 
-	r.HandleFunc("/fetch", fetchMotor).Methods("GET")
-	r.HandleFunc("/register", registerMotor).Methods("POST")
+	r.HandleFunc("/health", healthCheck).Methods("GET")
+	r.HandleFunc("/livez", livenessCheck).Methods("GET")
+	r.HandleFunc("/readyz", readinessCheck).Methods("GET")
+	r.Handle("/metrics", promhttp.Handler()).Methods("GET")
 
-	// Enable CORS
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000"}, // React frontend URL
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	})
+	v1 := r.PathPrefix("/v1").Subrouter()
+	registerAPIRoutes(v1, s)
+	// Built from the routes above, so it can't drift from them
+	r.HandleFunc("/openapi.json", openAPIHandler(v1, "/v1")).Methods("GET")
+
+	legacy := r.NewRoute().Subrouter()
+	legacy.Use(deprecatedAlias("/v1"))
+	registerAPIRoutes(legacy, s)
+	return r
+}
+
+// registerAPIRoutes adds the API endpoints to r
+func registerAPIRoutes(r *mux.Router, s *server) {
+	r.HandleFunc("/fetch", s.fetchMotor).Methods("GET")
+	r.HandleFunc("/schema/motor", motorJSONSchema).Methods("GET")
+	r.HandleFunc("/fetch/batch", s.fetchBatch).Methods("POST")
+	r.HandleFunc("/lookup", s.lookupAnyIdentifier).Methods("GET")
+	r.HandleFunc("/motor/{serial_no}", s.getMotor).Methods("GET")
+	r.HandleFunc("/motor/{serial_no}/certificate", s.motorCertificate).Methods("GET")
+	r.HandleFunc("/motor/{serial_no}/qr", s.motorQR).Methods("GET")
+
+	// Writes require a valid JWT
+	r.Handle("/register", requireAuth(s.cache.invalidating(s.idempotent(s.registerMotor)))).Methods("POST")
+	r.Handle("/register/bulk", requireAuth(s.cache.invalidating(http.HandlerFunc(s.registerMotorsBulk)))).Methods("POST")
+	r.Handle("/update/{serial_no}", requireAuth(s.cache.invalidating(http.HandlerFunc(s.updateMotor)))).Methods("PUT")
+	r.Handle("/motor/{serial_no}", requireAuth(s.cache.invalidating(http.HandlerFunc(s.patchMotor)))).Methods("PATCH")
+	r.Handle("/motor/{serial_no}", requireAuth(s.cache.invalidating(http.HandlerFunc(s.deleteMotor)))).Methods("DELETE")
+
+	if s.maintenance != nil {
+		r.HandleFunc("/admin/maintenance", s.maintenance.status).Methods("GET")
+		r.Handle("/admin/maintenance", requireAuth(http.HandlerFunc(s.maintenance.toggle))).Methods("PUT")
+	}
+
+	// The rest query Postgres directly, so they're left out when running on
+	// the in-memory store
+	if db != nil {
+		r.HandleFunc("/count", countMotors).Methods("GET")
+		r.HandleFunc("/stats", s.cache.cached("stats", motorStats)).Methods("GET")
+		r.HandleFunc("/expiring", expiringMotors).Methods("GET")
+		r.HandleFunc("/report", s.cache.cached("report", motorReport)).Methods("GET")
+		r.HandleFunc("/parties", s.cache.cached("parties", listParties)).Methods("GET")
+		r.HandleFunc("/transport-agencies", s.cache.cached("transport_agencies", listTransportAgencies)).Methods("GET")
+		r.HandleFunc("/search", searchMotors).Methods("GET")
+		r.HandleFunc("/fetch/query", queryMotors).Methods("POST")
+		r.HandleFunc("/export/csv", exportCSV).Methods("GET")
+		r.HandleFunc("/audit", listAudit).Methods("GET")
+		r.HandleFunc("/motor/id/{id:[0-9]+}", getMotorByID).Methods("GET")
+		r.HandleFunc("/motor/{serial_no}/claims", listClaims).Methods("GET")
+		r.HandleFunc("/motor/{serial_no}/history", motorHistory).Methods("GET")
+		r.HandleFunc("/warranty-policies", listPolicies).Methods("GET")
+		r.HandleFunc("/motor/{serial_no}/certificate/download", s.downloadCertificate).Methods("GET")
+
+		r.Handle("/motors/bulk-update", requireAuth(s.cache.invalidating(http.HandlerFunc(bulkUpdateMotors)))).Methods("PUT")
+		r.Handle("/import/csv", requireAuth(s.cache.invalidating(http.HandlerFunc(importMotorsCSV)))).Methods("POST")
+		r.Handle("/motor/{serial_no}/claims", requireAuth(http.HandlerFunc(fileClaim))).Methods("POST")
+		r.Handle("/motor/{serial_no}/extend", requireAuth(s.cache.invalidating(http.HandlerFunc(extendWarranty)))).Methods("POST")
+		r.Handle("/motor/{serial_no}/certificate/upload", requireAuth(http.HandlerFunc(s.uploadCertificate))).Methods("POST")
+		r.Handle("/claims/{id:[0-9]+}/status", requireAuth(http.HandlerFunc(updateClaimStatus))).Methods("PATCH")
+		r.Handle("/warranty-policies", requireAuth(http.HandlerFunc(createPolicy))).Methods("POST")
+		r.Handle("/warranty-policies/{id:[0-9]+}", requireAuth(http.HandlerFunc(updatePolicy))).Methods("PUT")
+		r.Handle("/warranty-policies/{id:[0-9]+}", requireAuth(http.HandlerFunc(deletePolicy))).Methods("DELETE")
+	}
+}
+
+// deprecatedAlias marks responses from an unversioned route as deprecated,
+// pointing at the same path under prefix
+func deprecatedAlias(prefix string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Set("Link", "<"+prefix+r.URL.Path+`>; rel="successor-version"`)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func main() {
+	migrateOnly := flag.Bool("migrate", false, "apply database migrations and exit")
+	flag.Parse()
+
+	slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: envLogLevel("LOG_LEVEL", slog.LevelInfo),
+	})))
+
+	// STORAGE=memory runs without Postgres, for demos and local testing
+	var store interface {
+		MotorStore
+		IdempotencyStore
+	}
+	switch storage := os.Getenv("STORAGE"); storage {
+	case "memory":
+		if *migrateOnly {
+			log.Fatal("-migrate needs STORAGE=postgres")
+		}
+		slog.Warn("Using in-memory storage; data will be lost on exit")
+		store = newInMemoryStore()
+	case "", "postgres":
+		initDB()
+		defer db.Close()
+
+		// Migrations are idempotent, so they run on every start as well
+		if err := runMigrations(context.Background(), db); err != nil {
+			log.Fatal("Failed to apply migrations: ", err)
+		}
+		if *migrateOnly {
+			return
+		}
+		store = newPostgresStore(db)
+	default:
+		log.Fatalf("Invalid STORAGE %q: must be postgres or memory", storage)
+	}
 
-	handler := c.Handler(r)
-	log.Println("Server running on :8080")
-	http.ListenAndServe(":8080", handler)
+	maxBodyBytes = int64(envInt("MAX_BODY_BYTES", int(maxBodyBytes)))
+	if n := envInt("MAX_RESULT_ROWS", maxResultRows); n > 0 {
+		maxResultRows = n
+	}
+	registerMetrics()
+	docs, err := newDocStoreFromEnv()
+	if err != nil {
+		log.Fatal("Failed to set up document storage: ", err)
+	}
+	s := &server{
+		store:       store,
+		mail:        newMailerFromEnv(),
+		hooks:       newWebhookDispatcherFromEnv(),
+		docs:        docs,
+		keys:        store,
+		cache:       newResponseCache(envDuration("AGGREGATE_CACHE_TTL", defaultAggregateCacheTTL)),
+		maintenance: newMaintenanceMode(envBool("MAINTENANCE_MODE", false)),
+		// REQUEST_TIMEOUT=0 turns request deadlines off
+		requestTimeout: envDuration("REQUEST_TIMEOUT", defaultRequestTimeout),
+	}
+	importTimeout := envDuration("IMPORT_TIMEOUT", defaultImportTimeout)
+	r := newRouter(s)
+
+	corsOptions, err := corsOptionsFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := cors.New(corsOptions)
+
+	// MAINTENANCE_MODE=true starts with writes turned away
+	var routes http.Handler = s.maintenance.middleware(r)
+	// Throttle each client IP; RATE_LIMIT_RPS=0 turns this off
+	if rps := envFloat("RATE_LIMIT_RPS", 10); rps > 0 {
+		routes = newIPRateLimiter(rps, envInt("RATE_LIMIT_BURST", 20)).middleware(routes)
+	}
+
+	routes = timeoutRequests(s.requestTimeout, importTimeout, routes)
+
+	handler := c.Handler(logRequests(gzipResponses(recoverPanics(routes))))
+	// Timeouts stop slow or stalled clients from holding connections open.
+	// Writes get longer so large CSV exports can finish.
+	srv := &http.Server{
+		Addr:              serverAddr(),
+		Handler:           handler,
+		ReadHeaderTimeout: envDuration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		ReadTimeout:       envDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		WriteTimeout:      envDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		IdleTimeout:       envDuration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+	}
+
+	// Serve HTTPS when given a certificate, plain HTTP otherwise
+	certFile, keyFile := os.Getenv("TLS_CERT_FILE"), os.Getenv("TLS_KEY_FILE")
+	if (certFile == "") != (keyFile == "") {
+		log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	go func() {
+		var err error
+		if certFile != "" {
+			slog.Info("Server running", "addr", srv.Addr, "tls", true)
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			slog.Info("Server running", "addr", srv.Addr)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	// Wait for a shutdown signal, then let in-flight requests drain
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+
+	slog.Info("Shutting down")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Error("Graceful shutdown failed", "error", err)
+	}
 }
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// With no filters fetchMotor must refuse the request before querying, rather
+// than looking up an empty serial_no. db is nil here, so reaching the
+// database would panic.
+func TestFetchMotorNoParams(t *testing.T) {
+	for _, query := range []string{"", "?serial_no=", "?serial_no=%20&party_name="} {
+		rec := httptest.NewRecorder()
+		(&server{}).fetchMotor(rec, httptest.NewRequest("GET", "/fetch"+query, nil))
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /fetch%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSONError(rec, http.StatusNotFound, "Motor not found")
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body struct {
+		Error  string `json:"error"`
+		Status int    `json:"status"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if body.Error != "Motor not found" || body.Status != http.StatusNotFound {
+		t.Errorf("body = %+v", body)
+	}
+}
+
+func TestRegisterMotorBodyTooLarge(t *testing.T) {
+	defer func(n int64) { maxBodyBytes = n }(maxBodyBytes)
+	maxBodyBytes = 16
+
+	body := strings.NewReader(`{"serial_no":"SN-1","motor_model":"M1","rpm":1440}`)
+	rec := httptest.NewRecorder()
+	(&server{}).registerMotor(rec, httptest.NewRequest("POST", "/register", body))
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestRegisterMotorUnknownField(t *testing.T) {
+	body := strings.NewReader(`{"serialno":"SN-1","motor_model":"M1","rpm":1440}`)
+	rec := httptest.NewRecorder()
+	(&server{}).registerMotor(rec, httptest.NewRequest("POST", "/register", body))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if !strings.Contains(rec.Body.String(), `serialno`) {
+		t.Errorf("body %s does not name the unknown field", rec.Body.String())
+	}
+}
+
+func TestBuildFetchQuery(t *testing.T) {
+	const orderBy = "ORDER BY dispatch_date DESC, id"
+	const allColumns = "id, serial_no, motor_model, rpm, phase, party_name, dispatch_date, " +
+		"transport_agency, lr_or_eway_bill, test_certificate, party_address, hp_kw, remarks, " +
+		"warranty_start_date, warranty_end_date, party_email, power_kw, created_at, updated_at, version"
+	tests := []struct {
+		name      string
+		query     string
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "serial only",
+			query:     "?serial_no=%20sn1",
+			wantQuery: "SELECT " + allColumns + " FROM motors WHERE (serial_no = $1 OR upper(trim(serial_no)) = $1) AND deleted_at IS NULL " + orderBy + " LIMIT $2 OFFSET $3",
+			wantArgs:  []interface{}{"SN1", 50, 0},
+		},
+		{
+			name:      "party only",
+			query:     "?party_name=Acme",
+			wantQuery: "SELECT " + allColumns + " FROM motors WHERE deleted_at IS NULL AND party_name = $1 " + orderBy + " LIMIT $2 OFFSET $3",
+			wantArgs:  []interface{}{"Acme", 50, 0},
+		},
+		{
+			name:      "serial and party",
+			query:     "?serial_no=SN1&party_name=Acme",
+			wantQuery: "SELECT " + allColumns + " FROM motors WHERE (serial_no = $1 OR upper(trim(serial_no)) = $1) AND deleted_at IS NULL AND party_name = $2 " + orderBy + " LIMIT $3 OFFSET $4",
+			wantArgs:  []interface{}{"SN1", "Acme", 50, 0},
+		},
+		{
+			name:    "neither",
+			query:   "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/fetch"+tt.query, nil)
+			filters, err := parseFetchFilters(r)
+			if err != nil {
+				t.Fatalf("parseFetchFilters: %v", err)
+			}
+			if err := addDeletedFilter(r, filters); err != nil {
+				t.Fatalf("addDeletedFilter: %v", err)
+			}
+
+			query, args, err := buildFetchQuery(selectColumns(nil), filters, orderBy, defaultFetchLimit, 0)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got query %q, want error", query)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if query != tt.wantQuery {
+				t.Errorf("query = %q, want %q", query, tt.wantQuery)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestNormalizeDate(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"2024-01-05", "2024-01-05", true},
+		{" 2024-01-05 ", "2024-01-05", true},
+		{"05/01/2024", "2024-01-05", true},
+		{"5/1/2024", "2024-01-05", true},
+		{"05-01-2024", "2024-01-05", true},
+		{"05.01.2024", "2024-01-05", true},
+		{"2024/01/05", "2024-01-05", true},
+		{"5 Jan 2024", "2024-01-05", true},
+		{"Jan 5, 2024", "2024-01-05", true},
+		{"31/02/2024", "31/02/2024", false},
+		{"yesterday", "yesterday", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := normalizeDate(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("normalizeDate(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRegisterMotorDryRun(t *testing.T) {
+	store := newInMemoryStore()
+	store.Register(context.Background(), Motor{SerialNo: "SN1"})
+	s := &server{store: store}
+
+	tests := []struct {
+		name, body   string
+		want         int
+		wantProblems int
+	}{
+		{"valid", `{"serial_no":"SN2","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`, http.StatusOK, 0},
+		{"duplicate serial", `{"serial_no":" sn1","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`, http.StatusUnprocessableEntity, 1},
+		{"several problems", `{"serial_no":"SN3","rpm":0,"phase":"two","dispatch_date":"2024-01-10"}`, http.StatusUnprocessableEntity, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.registerMotor(rec, httptest.NewRequest("POST", "/register?dry_run=true", strings.NewReader(tt.body)))
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			var body struct {
+				Problems []string `json:"problems"`
+			}
+			json.NewDecoder(rec.Body).Decode(&body)
+			if len(body.Problems) != tt.wantProblems {
+				t.Errorf("problems = %q, want %d", body.Problems, tt.wantProblems)
+			}
+		})
+	}
+
+	// Nothing was registered
+	if _, err := store.GetBySerial(context.Background(), "SN2"); err != ErrNotFound {
+		t.Errorf("GetBySerial(SN2): err = %v, want ErrNotFound", err)
+	}
+}
+
+// Liveness must hold even when the database is down, while readiness fails
+func TestLivenessIgnoresDatabase(t *testing.T) {
+	conn, err := sql.Open("postgres", "postgres://nobody@127.0.0.1:1/none?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer conn.Close()
+	prev := db
+	db = conn
+	defer func() { db = prev }()
+
+	rec := httptest.NewRecorder()
+	livenessCheck(rec, httptest.NewRequest("GET", "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("livez: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	rec = httptest.NewRecorder()
+	readinessCheck(rec, httptest.NewRequest("GET", "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("readyz: status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+// nullRow scans like a row whose columns at the given indexes are NULL and
+// whose others hold their destination's zero value
+type nullRow map[int]bool
+
+func (n nullRow) Scan(dest ...interface{}) error {
+	for i, d := range dest {
+		if s, ok := d.(sql.Scanner); ok {
+			var v interface{}
+			if !n[i] {
+				v = ""
+			}
+			if err := s.Scan(v); err != nil {
+				return err
+			}
+		} else if n[i] {
+			return errors.New("NULL scanned into non-nullable column")
+		}
+	}
+	return nil
+}
+
+// Rows imported with NULLs in the originally nullable columns still read,
+// as empty strings
+func TestScanMotorNulls(t *testing.T) {
+	// transport_agency through warranty_end_date in motorSelectColumns
+	nulls := nullRow{}
+	for i := 7; i <= 14; i++ {
+		nulls[i] = true
+	}
+	motor, err := scanMotor(nulls)
+	if err != nil {
+		t.Fatalf("scanMotor: %v", err)
+	}
+	if !reflect.DeepEqual(motor, Motor{}) {
+		t.Errorf("scanMotor = %+v, want the zero Motor", motor)
+	}
+}
+
+func TestSelectColumns(t *testing.T) {
+	for _, c := range []struct {
+		fields []string
+		want   []string
+	}{
+		{[]string{"serial_no"}, []string{"id", "serial_no"}},
+		{[]string{"serial_no", "lr_eway_bill", "phase"}, []string{"id", "serial_no", "phase", "lr_or_eway_bill"}},
+		{[]string{"serial_no", "warranty_status", "warranty_end_date"}, []string{"id", "serial_no", "warranty_end_date"}},
+	} {
+		if got := selectColumns(c.fields); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("selectColumns(%q) = %q, want %q", c.fields, got, c.want)
+		}
+	}
+	if got := selectColumns(nil); len(got) != len(motorColumnDests) {
+		t.Errorf("selectColumns(nil) = %q, want all %d columns", got, len(motorColumnDests))
+	}
+	for name := range motorResponse(Motor{}) {
+		if _, ok := fieldColumns[name]; !ok {
+			t.Errorf("response field %q has no columns in fieldColumns", name)
+		}
+	}
+}
+
+func TestScanMotorColumnsNulls(t *testing.T) {
+	motor, err := scanMotorColumns(nullRow{2: true}, []string{"id", "serial_no", "transport_agency"})
+	if err != nil {
+		t.Fatalf("scanMotorColumns: %v", err)
+	}
+	if !reflect.DeepEqual(motor, Motor{}) {
+		t.Errorf("scanMotorColumns = %+v, want the zero Motor", motor)
+	}
+}
+
+// limit is capped by maxFetchLimit and by MAX_RESULT_ROWS when that's lower
+func TestParsePaginationCaps(t *testing.T) {
+	defer func(n int) { maxResultRows = n }(maxResultRows)
+
+	for _, c := range []struct {
+		maxRows, want int
+		query         string
+	}{
+		{1000, defaultFetchLimit, ""},
+		{1000, maxFetchLimit, "limit=100000"},
+		{100, 100, "limit=100000"},
+		{100, 20, "limit=20"},
+	} {
+		maxResultRows = c.maxRows
+		limit, _, err := parsePagination(httptest.NewRequest("GET", "/fetch?"+c.query, nil))
+		if err != nil || limit != c.want {
+			t.Errorf("MAX_RESULT_ROWS=%d, %q: limit = %d, %v, want %d", c.maxRows, c.query, limit, err, c.want)
+		}
+	}
+}
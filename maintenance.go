@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// Write routes that only read, and the toggle itself, which has to keep
+// working so maintenance can be switched off again
+var maintenanceExemptPaths = []string{"/fetch/query", "/fetch/batch", "/admin/maintenance"}
+
+// maintenanceMode turns away writes while it's on, so the database can be
+// worked on without taking reads down too
+type maintenanceMode struct {
+	on atomic.Bool
+}
+
+// newMaintenanceMode returns a switch starting in the given state
+func newMaintenanceMode(on bool) *maintenanceMode {
+	m := &maintenanceMode{}
+	m.on.Store(on)
+	return m
+}
+
+// middleware answers 503 to POST, PUT, PATCH and DELETE requests while
+// maintenance is on. GETs and the exempt paths always go through.
+func (m *maintenanceMode) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !m.on.Load() || r.Method == "GET" || r.Method == "HEAD" || r.Method == "OPTIONS" || maintenanceExempt(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", "300")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"status": "maintenance"})
+	})
+}
+
+func maintenanceExempt(path string) bool {
+	for _, exempt := range maintenanceExemptPaths {
+		if strings.HasSuffix(path, exempt) {
+			return true
+		}
+	}
+	return false
+}
+
+// status reports whether maintenance is on
+func (m *maintenanceMode) status(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": m.on.Load()})
+}
+
+// toggle switches maintenance on or off from a body of {"enabled": bool}.
+// Only admins may do it.
+func (m *maintenanceMode) toggle(w http.ResponseWriter, r *http.Request) {
+	if !isAdmin(r) {
+		writeJSONError(w, http.StatusForbidden, "Changing maintenance mode requires an admin token")
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		writeJSONError(w, http.StatusBadRequest, `Expected {"enabled": true} or {"enabled": false}`)
+		return
+	}
+	m.on.Store(*body.Enabled)
+	m.status(w, r)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	m := newMaintenanceMode(true)
+	handler := m.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{"GET", "/v1/fetch?serial_no=SN1", http.StatusOK},
+		{"POST", "/v1/register", http.StatusServiceUnavailable},
+		{"PUT", "/update/SN1", http.StatusServiceUnavailable},
+		{"DELETE", "/v1/motor/SN1", http.StatusServiceUnavailable},
+		{"POST", "/v1/fetch/query", http.StatusOK},
+		{"PUT", "/v1/admin/maintenance", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+		}
+	}
+
+	m.on.Store(false)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/v1/register", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("POST after switching off: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestMaintenanceToggleRequiresAdmin(t *testing.T) {
+	m := newMaintenanceMode(false)
+	rec := httptest.NewRecorder()
+	m.toggle(rec, httptest.NewRequest("PUT", "/admin/maintenance", strings.NewReader(`{"enabled": true}`)))
+	if rec.Code != http.StatusForbidden || m.on.Load() {
+		t.Errorf("status = %d, enabled = %v; want 403 and still off", rec.Code, m.on.Load())
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryStore is a MotorStore that keeps motors in a map, for demos and
+// tests. It filters and sorts the same way PostgresStore does, but nothing
+// survives a restart and writes aren't audited.
+type InMemoryStore struct {
+	mu sync.RWMutex
+	// motors holds the live motors by serial, deleted the soft-deleted ones,
+	// which can share a serial with each other and with a live motor
+	motors  map[string]Motor
+	deleted []Motor
+	nextID  int64
+	// responses holds idempotent responses by caller and key, with when
+	// they were saved
+	responses map[idempotencyKey]savedIdempotentResponse
+}
+
+type idempotencyKey struct{ actor, key string }
+
+type savedIdempotentResponse struct {
+	IdempotentResponse
+	savedAt time.Time
+}
+
+func newInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		motors:    map[string]Motor{},
+		responses: map[idempotencyKey]savedIdempotentResponse{},
+	}
+}
+
+func (s *InMemoryStore) Register(ctx context.Context, motor Motor) (Motor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only live serials are taken, as with the partial unique index in Postgres
+	if _, ok := s.motors[motor.SerialNo]; ok {
+		return Motor{}, ErrDuplicateSerial
+	}
+	return s.add(motor), nil
+}
+
+func (s *InMemoryStore) RegisterBatch(ctx context.Context, motors []Motor) ([]Motor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Check the whole batch first so a failure stores nothing
+	seen := make(map[string]bool, len(motors))
+	for i, motor := range motors {
+		if _, ok := s.motors[motor.SerialNo]; ok || seen[motor.SerialNo] {
+			return nil, &BatchError{Index: i, Err: ErrDuplicateSerial}
+		}
+		seen[motor.SerialNo] = true
+	}
+	stored := make([]Motor, 0, len(motors))
+	for _, motor := range motors {
+		stored = append(stored, s.add(motor))
+	}
+	return stored, nil
+}
+
+// add stores a new motor, filling in the generated fields. s.mu must be held
+// for writing.
+func (s *InMemoryStore) add(motor Motor) Motor {
+	s.nextID++
+	motor.ID = s.nextID
+	motor.CreatedAt = time.Now()
+	motor.UpdatedAt = motor.CreatedAt
+	motor.Version = 1
+	s.motors[motor.SerialNo] = motor
+	return motor
+}
+
+func (s *InMemoryStore) Fetch(ctx context.Context, q MotorQuery) ([]Motor, int, error) {
+	if q.Filter.isEmpty() && !q.Cursor {
+		return nil, 0, errNoFilters
+	}
+
+	s.mu.RLock()
+	candidates := make([]Motor, 0, len(s.motors))
+	for _, motor := range s.motors {
+		candidates = append(candidates, motor)
+	}
+	if q.Filter.IncludeDeleted {
+		candidates = append(candidates, s.deleted...)
+	}
+	var matched []Motor
+	for _, motor := range candidates {
+		if q.Cursor && motor.ID <= q.After {
+			continue
+		}
+		if q.Filter.matches(motor) {
+			matched = append(matched, motor)
+		}
+	}
+	s.mu.RUnlock()
+
+	if q.Cursor {
+		sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+		return matched[:min(q.Limit, len(matched))], 0, nil
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := sortKey(matched[i], q.Sort.Column), sortKey(matched[j], q.Sort.Column)
+		if a == b {
+			return matched[i].ID < matched[j].ID
+		}
+		return (a < b) != q.Sort.Desc
+	})
+
+	total := len(matched)
+	start := min(q.Offset, total)
+	end := min(start+q.Limit, total)
+	return matched[start:end], total, nil
+}
+
+// find returns the live motor with serial, falling back like
+// liveSerialMatch to comparing normalized serials. s.mu must be held.
+func (s *InMemoryStore) find(serial string) (Motor, bool) {
+	if motor, ok := s.motors[serial]; ok {
+		return motor, true
+	}
+	var found Motor
+	for stored, motor := range s.motors {
+		if normalizeSerial(stored) == serial && (found.ID == 0 || motor.ID < found.ID) {
+			found = motor
+		}
+	}
+	return found, found.ID != 0
+}
+
+func (s *InMemoryStore) GetBySerial(ctx context.Context, serial string) (Motor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	motor, ok := s.find(serial)
+	if !ok {
+		return Motor{}, ErrNotFound
+	}
+	return motor, nil
+}
+
+func (s *InMemoryStore) GetBySerials(ctx context.Context, serials []string) ([]Motor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var motors []Motor
+	for _, serial := range serials {
+		if motor, ok := s.find(serial); ok {
+			motors = append(motors, motor)
+		}
+	}
+	return motors, nil
+}
+
+func (s *InMemoryStore) FindSimilar(ctx context.Context, serial string) (Motor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	serial = normalizeSerial(serial)
+	for stored, motor := range s.motors {
+		if normalizeSerial(stored) == serial {
+			return motor, nil
+		}
+	}
+	return Motor{}, ErrNotFound
+}
+
+func (s *InMemoryStore) Update(ctx context.Context, motor Motor) (Motor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.motors[motor.SerialNo]
+	if !ok {
+		return Motor{}, ErrNotFound
+	}
+	if motor.Version != current.Version {
+		return Motor{}, ErrVersionConflict
+	}
+	motor.ID = current.ID
+	motor.CreatedAt = current.CreatedAt
+	motor.UpdatedAt = time.Now()
+	motor.Version++
+	s.motors[motor.SerialNo] = motor
+	return motor, nil
+}
+
+// Patch stores motor whole: the caller applied fields over the stored record
+// at motor.Version, so the rest is unchanged whenever the version matches
+func (s *InMemoryStore) Patch(ctx context.Context, motor Motor, fields []string) (Motor, error) {
+	return s.Update(ctx, motor)
+}
+
+func (s *InMemoryStore) Delete(ctx context.Context, serial string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	motor, ok := s.find(serial)
+	if !ok {
+		return ErrNotFound
+	}
+	delete(s.motors, motor.SerialNo)
+	s.deleted = append(s.deleted, motor)
+	return nil
+}
+
+func (s *InMemoryStore) LookupResponse(ctx context.Context, actor, key string) (IdempotentResponse, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	saved, ok := s.responses[idempotencyKey{actor, key}]
+	if !ok || time.Since(saved.savedAt) >= idempotencyTTL {
+		return IdempotentResponse{}, false, nil
+	}
+	return saved.IdempotentResponse, true, nil
+}
+
+func (s *InMemoryStore) SaveResponse(ctx context.Context, actor, key string, resp IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, saved := range s.responses {
+		if time.Since(saved.savedAt) >= idempotencyTTL {
+			delete(s.responses, k)
+		}
+	}
+	if _, ok := s.responses[idempotencyKey{actor, key}]; !ok {
+		s.responses[idempotencyKey{actor, key}] = savedIdempotentResponse{IdempotentResponse: resp, savedAt: time.Now()}
+	}
+	return nil
+}
+
+// matches reports whether motor passes f, mirroring the SQL built from
+// f.conditions. Soft deletion isn't checked here.
+func (f MotorFilter) matches(motor Motor) bool {
+	if f.SerialNo != "" && motor.SerialNo != f.SerialNo && normalizeSerial(motor.SerialNo) != f.SerialNo {
+		return false
+	}
+	if len(f.PartyNames) == 1 && f.PartySearch {
+		if !strings.Contains(strings.ToLower(motor.PartyName), strings.ToLower(f.PartyNames[0])) {
+			return false
+		}
+	} else if len(f.PartyNames) > 0 && !slices.Contains(f.PartyNames, motor.PartyName) {
+		return false
+	}
+	if f.MotorModel != "" && motor.MotorModel != f.MotorModel {
+		return false
+	}
+	if f.Phase != "" && motor.Phase != f.Phase {
+		return false
+	}
+	if f.TransportAgency != "" && motor.TransportAgency != f.TransportAgency {
+		return false
+	}
+	if f.LREwayBill != "" && motor.LREwayBill != f.LREwayBill {
+		return false
+	}
+	// Dates are stored as YYYY-MM-DD, so they compare as strings just as
+	// they do in SQL
+	if f.DispatchFrom != "" && motor.DispatchDate < f.DispatchFrom {
+		return false
+	}
+	if f.DispatchTo != "" && motor.DispatchDate > f.DispatchTo {
+		return false
+	}
+	if f.MinKW != nil && (motor.PowerKW == nil || *motor.PowerKW < *f.MinKW) {
+		return false
+	}
+	if f.MaxKW != nil && (motor.PowerKW == nil || *motor.PowerKW > *f.MaxKW) {
+		return false
+	}
+	if f.MinRPM != nil && motor.RPM < *f.MinRPM {
+		return false
+	}
+	if f.MaxRPM != nil && motor.RPM > *f.MaxRPM {
+		return false
+	}
+	return true
+}
+
+// sortableTime formats timestamps at a fixed width, unlike RFC3339Nano which
+// drops trailing zeros, so that they sort as strings in time order
+const sortableTime = "2006-01-02T15:04:05.000000000Z"
+
+// sortKey returns the value of one of the sortableColumns as a string that
+// orders the same way
+func sortKey(motor Motor, column string) string {
+	switch column {
+	case "serial_no":
+		return motor.SerialNo
+	case "party_name":
+		return motor.PartyName
+	case "motor_model":
+		return motor.MotorModel
+	case "created_at":
+		return motor.CreatedAt.UTC().Format(sortableTime)
+	case "updated_at":
+		return motor.UpdatedAt.UTC().Format(sortableTime)
+	}
+	return motor.DispatchDate
+}
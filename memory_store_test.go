@@ -0,0 +1,591 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func seedMemoryStore(t *testing.T) *InMemoryStore {
+	t.Helper()
+	store := newInMemoryStore()
+	for _, m := range []Motor{
+		{SerialNo: "SN1", MotorModel: "M1", Phase: "three", PartyName: "Acme Pumps", DispatchDate: "2024-01-10", TransportAgency: "VRL"},
+		{SerialNo: "SN2", MotorModel: "M2", Phase: "single", PartyName: "Acme Pumps", DispatchDate: "2024-03-05", LREwayBill: "LR-1"},
+		{SerialNo: "SN3", MotorModel: "M1", Phase: "three", PartyName: "Bolt Works", DispatchDate: "2024-02-20", TransportAgency: "VRL", LREwayBill: "LR-1"},
+	} {
+		if _, err := store.Register(context.Background(), m); err != nil {
+			t.Fatalf("Register(%s): %v", m.SerialNo, err)
+		}
+	}
+	return store
+}
+
+func serials(motors []Motor) string {
+	var s []string
+	for _, m := range motors {
+		s = append(s, m.SerialNo)
+	}
+	return strings.Join(s, ",")
+}
+
+func TestInMemoryStoreFetch(t *testing.T) {
+	newest := sortOrder{Column: "dispatch_date", Desc: true}
+	tests := []struct {
+		name      string
+		query     MotorQuery
+		want      string
+		wantTotal int
+	}{
+		{"party exact", MotorQuery{Filter: MotorFilter{PartyNames: []string{"Acme Pumps"}}, Sort: newest, Limit: 50}, "SN2,SN1", 2},
+		{"party exact is case-sensitive", MotorQuery{Filter: MotorFilter{PartyNames: []string{"acme pumps"}}, Sort: newest, Limit: 50}, "", 0},
+		{"party search", MotorQuery{Filter: MotorFilter{PartyNames: []string{"WORK"}, PartySearch: true}, Sort: newest, Limit: 50}, "SN3", 1},
+		{"several parties", MotorQuery{Filter: MotorFilter{PartyNames: []string{"Bolt Works", "Acme Pumps"}}, Sort: newest, Limit: 50}, "SN2,SN3,SN1", 3},
+		{"model and phase", MotorQuery{Filter: MotorFilter{MotorModel: "M1", Phase: "three"}, Sort: newest, Limit: 50}, "SN3,SN1", 2},
+		{"transport agency", MotorQuery{Filter: MotorFilter{TransportAgency: "VRL"}, Sort: newest, Limit: 50}, "SN3,SN1", 2},
+		{"lr or eway bill", MotorQuery{Filter: MotorFilter{LREwayBill: "LR-1"}, Sort: newest, Limit: 50}, "SN2,SN3", 2},
+		{"dispatch range is inclusive", MotorQuery{Filter: MotorFilter{DispatchFrom: "2024-01-10", DispatchTo: "2024-02-20"}, Sort: newest, Limit: 50}, "SN3,SN1", 2},
+		{"ascending serial", MotorQuery{Filter: MotorFilter{DispatchFrom: "2024-01-01"}, Sort: sortOrder{Column: "serial_no"}, Limit: 50}, "SN1,SN2,SN3", 3},
+		{"paged", MotorQuery{Filter: MotorFilter{DispatchFrom: "2024-01-01"}, Sort: newest, Limit: 1, Offset: 1}, "SN3", 3},
+		{"offset past the end", MotorQuery{Filter: MotorFilter{DispatchFrom: "2024-01-01"}, Sort: newest, Limit: 10, Offset: 10}, "", 3},
+	}
+
+	store := seedMemoryStore(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := store.Fetch(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if serials(got) != tt.want || total != tt.wantTotal {
+				t.Errorf("got %q (total %d), want %q (total %d)", serials(got), total, tt.want, tt.wantTotal)
+			}
+		})
+	}
+
+	if _, _, err := store.Fetch(context.Background(), MotorQuery{Sort: newest, Limit: 50}); err == nil {
+		t.Error("Fetch with no filters: want error")
+	}
+}
+
+func TestInMemoryStoreDelete(t *testing.T) {
+	ctx := context.Background()
+	store := seedMemoryStore(t)
+
+	if err := store.Delete(ctx, "SN1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, "SN1"); err != ErrNotFound {
+		t.Errorf("second Delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetBySerial(ctx, "SN1"); err != ErrNotFound {
+		t.Errorf("GetBySerial after delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := store.Update(ctx, Motor{SerialNo: "SN1"}); err != ErrNotFound {
+		t.Errorf("Update after delete: err = %v, want ErrNotFound", err)
+	}
+
+	filter := MotorFilter{PartyNames: []string{"Acme Pumps"}}
+	if got, _, _ := store.Fetch(ctx, MotorQuery{Filter: filter, Limit: 50}); serials(got) != "SN2" {
+		t.Errorf("Fetch = %q, want SN2", serials(got))
+	}
+	filter.IncludeDeleted = true
+	if _, total, _ := store.Fetch(ctx, MotorQuery{Filter: filter, Limit: 50}); total != 2 {
+		t.Errorf("Fetch with IncludeDeleted: total = %d, want 2", total)
+	}
+}
+
+// A motor deleted by mistake can be registered again, as a new record
+func TestInMemoryStoreReregisterDeleted(t *testing.T) {
+	ctx := context.Background()
+	store := seedMemoryStore(t)
+	old, _ := store.GetBySerial(ctx, "SN1")
+
+	if err := store.Delete(ctx, "SN1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	again, err := store.Register(ctx, Motor{SerialNo: "SN1", PartyName: "Acme Pumps"})
+	if err != nil {
+		t.Fatalf("Register after delete: %v", err)
+	}
+	if again.ID == old.ID {
+		t.Errorf("re-registered motor kept id %d", old.ID)
+	}
+	if _, err := store.Register(ctx, Motor{SerialNo: "SN1"}); err != ErrDuplicateSerial {
+		t.Errorf("second Register: err = %v, want ErrDuplicateSerial", err)
+	}
+	if got, err := store.GetBySerial(ctx, "SN1"); err != nil || got.ID != again.ID {
+		t.Errorf("GetBySerial = id %d, %v, want id %d", got.ID, err, again.ID)
+	}
+	// Both are at version 1, so a cached copy of the old one must not match
+	if motorETag(again) == motorETag(old) {
+		t.Errorf("re-registered motor has the deleted one's ETag %s", motorETag(old))
+	}
+
+	filter := MotorFilter{SerialNo: "SN1"}
+	if _, total, _ := store.Fetch(ctx, MotorQuery{Filter: filter, Limit: 50}); total != 1 {
+		t.Errorf("Fetch: total = %d, want 1", total)
+	}
+	filter.IncludeDeleted = true
+	if _, total, _ := store.Fetch(ctx, MotorQuery{Filter: filter, Limit: 50}); total != 2 {
+		t.Errorf("Fetch with IncludeDeleted: total = %d, want 2", total)
+	}
+}
+
+// The store-backed routes work end to end without a database
+func TestRouterWithInMemoryStore(t *testing.T) {
+	testRouterWithInMemoryStore(t, "")
+}
+
+func TestRouterWithInMemoryStoreV1(t *testing.T) {
+	testRouterWithInMemoryStore(t, "/v1")
+}
+
+// testRouterWithInMemoryStore registers and fetches a motor under prefix
+func testRouterWithInMemoryStore(t *testing.T, prefix string) {
+	t.Setenv("JWT_SECRET", "memory-test-secret")
+	srv := httptest.NewServer(newRouter(&server{store: newInMemoryStore()}))
+	defer srv.Close()
+
+	body := `{"serial_no":"SN1","motor_model":"M1","rpm":1440,"phase":"three","party_name":"Acme","dispatch_date":"2024-01-10"}`
+	req, _ := http.NewRequest("POST", srv.URL+prefix+"/register", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken(t))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST %s/register: %v", prefix, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST %s/register: status = %d, want %d", prefix, resp.StatusCode, http.StatusCreated)
+	}
+
+	resp, err = http.Get(srv.URL + prefix + "/fetch?party_name=Acme")
+	if err != nil {
+		t.Fatalf("GET %s/fetch: %v", prefix, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("GET %s/fetch: status = %d, want %d", prefix, resp.StatusCode, http.StatusOK)
+	}
+
+	// Postgres-only routes aren't registered
+	resp, err = http.Get(srv.URL + prefix + "/count")
+	if err != nil {
+		t.Fatalf("GET %s/count: %v", prefix, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET %s/count: status = %d, want %d", prefix, resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+// Unprefixed routes still answer, but point clients at /v1
+func TestDeprecatedAliasRoutes(t *testing.T) {
+	router := newRouter(&server{store: newInMemoryStore()})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/motor/SN1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /motor/SN1: status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if got := rec.Header().Get("Deprecation"); got != "true" {
+		t.Errorf("GET /motor/SN1: Deprecation = %q, want %q", got, "true")
+	}
+	if got, want := rec.Header().Get("Link"), `</v1/motor/SN1>; rel="successor-version"`; got != want {
+		t.Errorf("GET /motor/SN1: Link = %q, want %q", got, want)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/v1/motor/SN1", nil))
+	if got := rec.Header().Get("Deprecation"); got != "" {
+		t.Errorf("GET /v1/motor/SN1: Deprecation = %q, want none", got)
+	}
+}
+
+func TestRegisterNearDuplicateSerial(t *testing.T) {
+	store := newInMemoryStore()
+	// Stored before serials were normalized
+	store.Register(context.Background(), Motor{SerialNo: "abc123 ", MotorModel: "M1"})
+	s := &server{store: store}
+
+	body := `{"serial_no":" Abc123","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`
+	rec := httptest.NewRecorder()
+	s.registerMotor(rec, httptest.NewRequest("POST", "/register", strings.NewReader(body)))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if !strings.Contains(rec.Body.String(), `"serial_no":"abc123 "`) {
+		t.Errorf("body %s does not include the conflicting record", rec.Body.String())
+	}
+
+	body = `{"serial_no":"xyz789 ","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`
+	rec = httptest.NewRecorder()
+	s.registerMotor(rec, httptest.NewRequest("POST", "/register", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusCreated)
+	}
+	if _, err := store.GetBySerial(context.Background(), "XYZ789"); err != nil {
+		t.Errorf("serial was not stored normalized: %v", err)
+	}
+}
+
+func TestRegisterMotorsBulk(t *testing.T) {
+	store := newInMemoryStore()
+	store.Register(context.Background(), Motor{SerialNo: "SN1", MotorModel: "M1"})
+	s := &server{store: store}
+	motor := `{"serial_no":"%s","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`
+
+	body := "[" + fmt.Sprintf(motor, "SN2") + "," + fmt.Sprintf(motor, "sn1") + "]"
+	rec := httptest.NewRecorder()
+	s.registerMotorsBulk(rec, httptest.NewRequest("POST", "/register/bulk", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if !strings.Contains(rec.Body.String(), `"index":1`) {
+		t.Errorf("body %s does not name the duplicate", rec.Body.String())
+	}
+	if _, err := store.GetBySerial(context.Background(), "SN2"); err != ErrNotFound {
+		t.Errorf("GetBySerial(SN2) err = %v, want nothing stored from a failed batch", err)
+	}
+
+	body = "[" + fmt.Sprintf(motor, "SN2") + "," + fmt.Sprintf(motor, "SN3") + "]"
+	rec = httptest.NewRecorder()
+	s.registerMotorsBulk(rec, httptest.NewRequest("POST", "/register/bulk", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"inserted":2`) {
+		t.Fatalf("got %d %s, want %d with 2 inserted", rec.Code, rec.Body.String(), http.StatusCreated)
+	}
+}
+
+// Bulk registration catches older serials that only differ in case or
+// spacing, as /register does
+func TestRegisterMotorsBulkNearDuplicate(t *testing.T) {
+	store := newInMemoryStore()
+	// Stored before serials were normalized
+	store.Register(context.Background(), Motor{SerialNo: "abc123 ", MotorModel: "M1"})
+	s := &server{store: store}
+	motor := `{"serial_no":"%s","motor_model":"M1","rpm":1440,"phase":"three","dispatch_date":"2024-01-10"}`
+
+	body := "[" + fmt.Sprintf(motor, "SN2") + "," + fmt.Sprintf(motor, " Abc123") + "]"
+	rec := httptest.NewRecorder()
+	s.registerMotorsBulk(rec, httptest.NewRequest("POST", "/register/bulk", strings.NewReader(body)))
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusConflict)
+	}
+	if !strings.Contains(rec.Body.String(), `"index":1`) || !strings.Contains(rec.Body.String(), `"serial_no":"abc123 "`) {
+		t.Errorf("body %s does not name the row and the conflicting record", rec.Body.String())
+	}
+	if _, err := store.GetBySerial(context.Background(), "SN2"); err != ErrNotFound {
+		t.Errorf("GetBySerial(SN2) err = %v, want nothing stored from a failed batch", err)
+	}
+}
+
+func TestInMemoryStoreUpdateVersion(t *testing.T) {
+	ctx := context.Background()
+	store := seedMemoryStore(t)
+
+	motor, _ := store.GetBySerial(ctx, "SN1")
+	motor.Remarks = "first edit"
+	updated, err := store.Update(ctx, motor)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Version != motor.Version+1 {
+		t.Errorf("version = %d, want %d", updated.Version, motor.Version+1)
+	}
+
+	// A second edit based on the same read loses the race
+	motor.Remarks = "stale edit"
+	if _, err := store.Update(ctx, motor); err != ErrVersionConflict {
+		t.Errorf("stale Update: err = %v, want ErrVersionConflict", err)
+	}
+}
+
+func TestUpdateMotorRequiresVersion(t *testing.T) {
+	s := &server{store: seedMemoryStore(t)}
+	r := httptest.NewRequest("PUT", "/update/SN1", strings.NewReader(`{"remarks":"edit"}`))
+	r = mux.SetURLVars(r, map[string]string{"serial_no": "SN1"})
+	rec := httptest.NewRecorder()
+	s.updateMotor(rec, r)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestPatchMotor(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"partial", `{"version":1,"rpm":1440,"remarks":"rewound"}`, http.StatusOK},
+		{"no version", `{"rpm":1440}`, http.StatusBadRequest},
+		{"immutable", `{"version":1,"serial_no":"SN9"}`, http.StatusBadRequest},
+		{"unknown", `{"version":1,"colour":"red"}`, http.StatusBadRequest},
+		{"nothing to change", `{"version":1}`, http.StatusBadRequest},
+		{"stale", `{"version":7,"rpm":1440}`, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := seedMemoryStore(t)
+			r := httptest.NewRequest("PATCH", "/motor/SN1", strings.NewReader(tt.body))
+			r = mux.SetURLVars(r, map[string]string{"serial_no": "SN1"})
+			rec := httptest.NewRecorder()
+			(&server{store: store}).patchMotor(rec, r)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			motor, _ := store.GetBySerial(context.Background(), "SN1")
+			if motor.RPM != 1440 || motor.Remarks != "rewound" || motor.PartyName != "Acme Pumps" || motor.Version != 2 {
+				t.Errorf("stored motor = %+v", motor)
+			}
+		})
+	}
+}
+
+// Cursor pages walk the whole table in id order, unaffected by inserts
+// between requests
+func TestFetchMotorCursor(t *testing.T) {
+	store := seedMemoryStore(t)
+	s := &server{store: store}
+
+	page := func(after string) (serials []string, next interface{}) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		s.fetchMotor(rec, httptest.NewRequest("GET", "/fetch?mode=cursor&limit=2&after="+after, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+		}
+		var body struct {
+			Data       []Motor     `json:"data"`
+			NextCursor interface{} `json:"next_cursor"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		for _, m := range body.Data {
+			serials = append(serials, m.SerialNo)
+		}
+		return serials, body.NextCursor
+	}
+
+	first, next := page("")
+	if strings.Join(first, ",") != "SN1,SN2" || next == nil {
+		t.Fatalf("first page = %v, next = %v", first, next)
+	}
+	store.Register(context.Background(), Motor{SerialNo: "SN4", PartyName: "Acme Pumps"})
+	second, next := page(next.(string))
+	if strings.Join(second, ",") != "SN3,SN4" || next != nil {
+		t.Errorf("second page = %v, next = %v; want the last page", second, next)
+	}
+	beyond, next := page(encodeCursor(99))
+	if len(beyond) != 0 || next != nil {
+		t.Errorf("page past the end = %v, next = %v; want empty", beyond, next)
+	}
+
+	for _, query := range []string{"offset=10", "limit=0"} {
+		rec := httptest.NewRecorder()
+		s.fetchMotor(rec, httptest.NewRequest("GET", "/fetch?mode=cursor&"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("cursor with %s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestFetchMotorFields(t *testing.T) {
+	s := &server{store: seedMemoryStore(t)}
+
+	rec := httptest.NewRecorder()
+	s.fetchMotor(rec, httptest.NewRequest("GET", "/fetch?serial_no=SN1&fields=phase,%20party_name,phase", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var body struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	want := map[string]interface{}{"serial_no": "SN1", "phase": "three", "party_name": "Acme Pumps"}
+	if len(body.Data) != 1 || !reflect.DeepEqual(body.Data[0], want) {
+		t.Errorf("data = %v, want [%v]", body.Data, want)
+	}
+
+	rec = httptest.NewRecorder()
+	s.fetchMotor(rec, httptest.NewRequest("GET", "/fetch?serial_no=SN1&fields=phase,deleted_at", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestGetMotorETag(t *testing.T) {
+	store := seedMemoryStore(t)
+	router := newRouter(&server{store: store})
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/v1/motor/SN1", nil)
+		if ifNoneMatch != "" {
+			r.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec
+	}
+
+	first := get("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("status = %d, ETag = %q; want 200 with an ETag", first.Code, etag)
+	}
+	for _, header := range []string{etag, `"other", W/` + etag, "*"} {
+		if rec := get(header); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: status = %d, body %q; want an empty 304", header, rec.Code, rec.Body)
+		}
+	}
+
+	motor, _ := store.GetBySerial(context.Background(), "SN1")
+	motor.Remarks = "rewound"
+	if _, err := store.Update(context.Background(), motor); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if rec := get(etag); rec.Code != http.StatusOK || rec.Header().Get("ETag") == etag {
+		t.Errorf("after an update: status = %d, ETag = %q; want 200 with a new ETag", rec.Code, rec.Header().Get("ETag"))
+	}
+}
+
+func TestFetchBatch(t *testing.T) {
+	store := seedMemoryStore(t)
+	if err := store.Delete(context.Background(), "SN2"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	s := &server{store: store}
+
+	rec := httptest.NewRecorder()
+	body := strings.NewReader(`{"serial_nos": ["SN3", " SN1", "SN2", "SN9", "SN3"]}`)
+	s.fetchBatch(rec, httptest.NewRequest("POST", "/fetch/batch", body))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body)
+	}
+	var got struct {
+		Data     []Motor  `json:"data"`
+		NotFound []string `json:"not_found"`
+	}
+	json.NewDecoder(rec.Body).Decode(&got)
+	if serials(got.Data) != "SN3,SN1" || strings.Join(got.NotFound, ",") != "SN2,SN9" {
+		t.Errorf("data = %s, not_found = %v; want SN3,SN1 and [SN2 SN9]", serials(got.Data), got.NotFound)
+	}
+
+	many := make([]string, maxBatchSerials+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("SN%d", i)
+	}
+	tooMany, _ := json.Marshal(map[string][]string{"serial_nos": many})
+	for _, body := range []string{`{"serial_nos": []}`, `{"serial_nos": [" "]}`, `[]`, string(tooMany)} {
+		rec := httptest.NewRecorder()
+		s.fetchBatch(rec, httptest.NewRequest("POST", "/fetch/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want %d", body, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Serials are stored uppercased, so every route must find a motor by the
+// serial the client registered it with
+func TestLowercaseSerialRoundTrip(t *testing.T) {
+	t.Setenv("JWT_SECRET", "memory-test-secret")
+	store := newInMemoryStore()
+	srv := httptest.NewServer(newRouter(&server{store: store}))
+	defer srv.Close()
+
+	do := func(method, path, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+testToken(t))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	register := `{"serial_no":"abc123","motor_model":"M1","rpm":1440,"phase":"three","party_name":"Acme","dispatch_date":"2024-01-10"}`
+	if got := do("POST", "/v1/register", register); got != http.StatusCreated {
+		t.Fatalf("POST /v1/register: status = %d, want %d", got, http.StatusCreated)
+	}
+	for _, c := range []struct{ method, path, body string }{
+		{"GET", "/v1/motor/abc123", ""},
+		{"GET", "/v1/fetch?serial_no=abc123", ""},
+		{"POST", "/v1/fetch/batch", `{"serial_nos":["abc123"]}`},
+		{"GET", "/v1/lookup?q=abc123", ""},
+		{"PUT", "/v1/update/abc123", `{"version":1,"remarks":"checked"}`},
+		{"DELETE", "/v1/motor/abc123", ""},
+	} {
+		if got := do(c.method, c.path, c.body); got != http.StatusOK {
+			t.Errorf("%s %s: status = %d, want %d", c.method, c.path, got, http.StatusOK)
+		}
+	}
+
+	// Motors stored before serials were normalized are still found
+	if _, err := store.Register(context.Background(), Motor{SerialNo: "legacy-1 ", PartyName: "Acme"}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if got := do("GET", "/v1/motor/LEGACY-1", ""); got != http.StatusOK {
+		t.Errorf("GET legacy motor: status = %d, want %d", got, http.StatusOK)
+	}
+	if got := do("DELETE", "/v1/motor/legacy-1", ""); got != http.StatusOK {
+		t.Errorf("DELETE legacy motor: status = %d, want %d", got, http.StatusOK)
+	}
+}
+
+// Listings flag when more motors matched than they returned
+func TestFetchMarksTruncated(t *testing.T) {
+	defer func(n int) { maxResultRows = n }(maxResultRows)
+	s := &server{store: seedMemoryStore(t)}
+	for _, c := range []struct {
+		maxRows int
+		query   string
+		want    bool
+	}{
+		{1000, "party_name=Acme%20Pumps&limit=1", true},
+		{1000, "party_name=Acme%20Pumps&limit=1&offset=1", false},
+		{1000, "party_name=Acme%20Pumps", false},
+		// MAX_RESULT_ROWS cuts the requested page short
+		{1, "party_name=Acme%20Pumps&limit=2", true},
+	} {
+		maxResultRows = c.maxRows
+		rec := httptest.NewRecorder()
+		s.fetchMotor(rec, httptest.NewRequest("GET", "/fetch?"+c.query, nil))
+		var resp struct {
+			Truncated bool `json:"truncated"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("%s: decoding response: %v", c.query, err)
+		}
+		header := rec.Header().Get(resultTruncatedHeader) == "true"
+		if resp.Truncated != c.want || header != c.want {
+			t.Errorf("%s: truncated = %v, header %v, want %v", c.query, resp.Truncated, header, c.want)
+		}
+	}
+}
+
+// Timestamps sort in time order whatever their fractional seconds
+func TestSortKeyTimes(t *testing.T) {
+	base := time.Date(2024, 1, 10, 9, 30, 5, 0, time.UTC)
+	times := []time.Time{base, base.Add(100 * time.Millisecond), base.Add(120 * time.Millisecond), base.Add(500 * time.Millisecond)}
+	for i := 1; i < len(times); i++ {
+		a, b := sortKey(Motor{CreatedAt: times[i-1]}, "created_at"), sortKey(Motor{CreatedAt: times[i]}, "created_at")
+		if a >= b {
+			t.Errorf("sortKey(%v) = %q doesn't sort before sortKey(%v) = %q", times[i-1], a, times[i], b)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests served, by method, route and status.",
+	}, []string{"method", "path", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "path"})
+
+	dbErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "db_errors_total",
+		Help: "Failed database calls, by route.",
+	}, []string{"path"})
+)
+
+// registerMetrics adds the service's collectors to the default registry
+func registerMetrics() {
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, dbErrorsTotal, cacheRequestsTotal)
+}
+
+// routeLabel names the matched route by its template, e.g. /motor/{serial_no},
+// so the label doesn't grow with every serial number
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
+
+// recordMetrics is router middleware that counts and times each request
+func recordMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		path := routeLabel(r)
+		httpRequestsTotal.WithLabelValues(r.Method, path, strconv.Itoa(rec.status)).Inc()
+		httpRequestDuration.WithLabelValues(r.Method, path).Observe(time.Since(start).Seconds())
+	})
+}
+
+// dbError counts a failed database call and responds with a 500, or a 503
+// when the call failed because the request ran out of time
+func dbError(w http.ResponseWriter, r *http.Request, msg string) {
+	dbErrorsTotal.WithLabelValues(routeLabel(r)).Inc()
+	if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+		writeJSONError(w, http.StatusServiceUnavailable, "Request timed out")
+		return
+	}
+	writeJSONError(w, http.StatusInternalServerError, msg)
+}
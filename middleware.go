@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+// requestIDFrom returns the ID assigned to the request by logRequests
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.wroteHeader = true
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// logRequests tags each request with a UUID, returned in X-Request-ID, and
+// logs its method, path, status and duration once it completes
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := uuid.NewString()
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r)
+
+		slog.Info("request",
+			"request_id", id,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// recoverPanics turns a panicking handler into a 500 for that request alone,
+// logging the panic and stack with the request ID. It goes inside
+// logRequests so the ID is set and the 500 is logged.
+func recoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// net/http uses this panic to abort a response on purpose
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			slog.Error("Handler panicked",
+				"request_id", requestIDFrom(r.Context()),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"panic", err,
+				"stack", string(debug.Stack()),
+			)
+			if rec.wroteHeader {
+				// Part of the response is already out, so all that can be
+				// done is to cut it short
+				panic(http.ErrAbortHandler)
+			}
+			writeJSONError(rec, http.StatusInternalServerError, "Internal server error")
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// Defaults for REQUEST_TIMEOUT and IMPORT_TIMEOUT
+const (
+	defaultRequestTimeout = 15 * time.Second
+	defaultImportTimeout  = 5 * time.Minute
+)
+
+// timeoutRequests gives each request a deadline of timeout. The deadline
+// cancels the request's database calls, after which dbError answers 503; it
+// doesn't interrupt a handler that is busy with anything else, which runs
+// on until it next touches the database. CSV imports, which insert up to
+// maxImportRows in one transaction, get importTimeout instead, and their
+// write deadline is pushed out to match so the report still reaches the
+// client. CSV exports stream for as long as they need and are bounded by
+// the server's write timeout. A timeout of zero turns its deadline off.
+func timeoutRequests(timeout, importTimeout time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		budget := timeout
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/export/csv"):
+			budget = 0
+		case strings.HasSuffix(r.URL.Path, "/import/csv"):
+			budget = importTimeout
+			if budget > 0 {
+				// Not every writer supports this, in which case the
+				// server's write timeout still applies
+				http.NewResponseController(w).SetWriteDeadline(time.Now().Add(budget))
+			}
+		}
+		if budget <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), budget)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
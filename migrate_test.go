@@ -0,0 +1,22 @@
+package main
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	for i, m := range migrations {
+		if m.sql == "" {
+			t.Errorf("migration %s is empty", m.name)
+		}
+		if i > 0 && m.version <= migrations[i-1].version {
+			t.Errorf("migration %s is out of order after %s", m.name, migrations[i-1].name)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS motors (
+    serial_no        VARCHAR(100) NOT NULL,
+    motor_model      VARCHAR(100) NOT NULL,
+    rpm              INTEGER      NOT NULL,
+    phase            VARCHAR(20)  NOT NULL,
+    party_name       VARCHAR(255) NOT NULL,
+    dispatch_date    VARCHAR(10)  NOT NULL,
+    transport_agency VARCHAR(255),
+    lr_or_eway_bill  VARCHAR(100),
+    test_certificate VARCHAR(255),
+    party_address    TEXT,
+    hp_kw            VARCHAR(50),
+    remarks          TEXT
+);
@@ -0,0 +1,2 @@
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS warranty_start_date VARCHAR(10);
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS warranty_end_date VARCHAR(10);
@@ -0,0 +1,2 @@
+-- Serial numbers are the lookup key and must not repeat
+CREATE UNIQUE INDEX IF NOT EXISTS motors_serial_no_key ON motors (serial_no);
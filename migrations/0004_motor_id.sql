@@ -0,0 +1,22 @@
+-- A generated id is the primary key so records keep their identity when a
+-- serial number is corrected
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS id BIGSERIAL;
+DO $$
+DECLARE
+    pkey TEXT;
+BEGIN
+    SELECT conname INTO pkey FROM pg_constraint WHERE conrelid = 'motors'::regclass AND contype = 'p';
+    -- Legacy tables often made serial_no the key. id takes over from it, and
+    -- 0003's index keeps serials unique.
+    IF pkey IS NOT NULL AND EXISTS (
+        SELECT 1 FROM pg_constraint c
+          JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = ANY (c.conkey)
+         WHERE c.conrelid = 'motors'::regclass AND c.conname = pkey AND a.attname = 'serial_no'
+    ) THEN
+        EXECUTE format('ALTER TABLE motors DROP CONSTRAINT %I', pkey);
+        pkey := NULL;
+    END IF;
+    IF pkey IS NULL THEN
+        ALTER TABLE motors ADD PRIMARY KEY (id);
+    END IF;
+END $$;
@@ -0,0 +1,2 @@
+-- Deleted motors are kept and marked rather than removed
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
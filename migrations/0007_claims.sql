@@ -0,0 +1,12 @@
+-- Warranty claims filed against a motor
+CREATE TABLE IF NOT EXISTS claims (
+    id                BIGSERIAL    PRIMARY KEY,
+    serial_no         VARCHAR(100) NOT NULL REFERENCES motors (serial_no),
+    claim_date        VARCHAR(10)  NOT NULL,
+    description       TEXT         NOT NULL,
+    status            VARCHAR(20)  NOT NULL DEFAULT 'open',
+    status_changed_at TIMESTAMPTZ,
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS claims_serial_no_idx ON claims (serial_no);
@@ -0,0 +1,10 @@
+-- Every write to a motor, recorded in the same transaction as the change
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         BIGSERIAL    PRIMARY KEY,
+    action     VARCHAR(20)  NOT NULL,
+    serial_no  VARCHAR(100) NOT NULL,
+    payload    JSONB        NOT NULL,
+    actor      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS audit_log_serial_no_idx ON audit_log (serial_no, created_at);
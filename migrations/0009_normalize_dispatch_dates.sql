@@ -0,0 +1,19 @@
+-- Older rows may have DD/MM/YYYY style dispatch dates; rewrite them as
+-- YYYY-MM-DD so range filters compare correctly. Rows that don't parse as
+-- real dates are left alone rather than failing the migration.
+DO $$
+DECLARE
+    r RECORD;
+BEGIN
+    FOR r IN SELECT id, dispatch_date FROM motors
+             WHERE dispatch_date ~ '^\s*[0-9]{1,2}[/.-][0-9]{1,2}[/.-][0-9]{4}\s*$'
+    LOOP
+        BEGIN
+            UPDATE motors
+            SET dispatch_date = to_char(to_date(regexp_replace(trim(r.dispatch_date), '[/.-]', '/', 'g'), 'DD/MM/YYYY'), 'YYYY-MM-DD')
+            WHERE id = r.id;
+        EXCEPTION WHEN others THEN
+            RAISE NOTICE 'leaving dispatch_date % of motor % as is', r.dispatch_date, r.id;
+        END;
+    END LOOP;
+END $$;
@@ -0,0 +1,2 @@
+-- Where registration confirmations for the party are sent
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS party_email VARCHAR(255) NOT NULL DEFAULT '';
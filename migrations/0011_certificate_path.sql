@@ -0,0 +1,2 @@
+-- Where the uploaded test certificate scan is stored, empty when none is
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS certificate_path TEXT NOT NULL DEFAULT '';
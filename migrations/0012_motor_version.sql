@@ -0,0 +1,2 @@
+-- Bumped on every update so concurrent edits can be detected
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- hp_kw is free text; power_kw holds the same rating in kW so it can be
+-- filtered numerically. Bare numbers are taken as HP, and rows that don't
+-- look like a rating are left NULL.
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS power_kw NUMERIC(10, 3);
+
+UPDATE motors SET power_kw = CASE
+    WHEN lower(hp_kw) ~ '[0-9]\s*kw'
+        THEN substring(lower(hp_kw) from '([0-9]+(\.[0-9]+)?)\s*kw')::numeric
+    WHEN lower(hp_kw) ~ '^\s*[0-9]+(\.[0-9]+)?\s*(hp)?\s*$'
+        THEN round(substring(hp_kw from '([0-9]+(\.[0-9]+)?)')::numeric * 0.7457, 3)
+END
+WHERE power_kw IS NULL;
+
+CREATE INDEX IF NOT EXISTS motors_power_kw_idx ON motors (power_kw);
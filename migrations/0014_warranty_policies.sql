@@ -0,0 +1,17 @@
+-- Warranty periods by phase and/or motor model. An empty phase or model
+-- matches any motor, so the ('', '') row is the global default.
+CREATE TABLE IF NOT EXISTS warranty_policies (
+    id          BIGSERIAL    PRIMARY KEY,
+    phase       VARCHAR(20)  NOT NULL DEFAULT '',
+    motor_model VARCHAR(100) NOT NULL DEFAULT '',
+    months      INTEGER      NOT NULL CHECK (months > 0),
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ  NOT NULL DEFAULT now(),
+    UNIQUE (phase, motor_model)
+);
+
+INSERT INTO warranty_policies (phase, motor_model, months) VALUES
+    ('', '', 12),
+    ('single', '', 12),
+    ('three', '', 24)
+ON CONFLICT (phase, motor_model) DO NOTHING;
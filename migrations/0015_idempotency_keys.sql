@@ -0,0 +1,10 @@
+-- Responses to requests sent with an Idempotency-Key, replayed when a client
+-- retries with the same key. Rows older than a day are ignored and pruned.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key         VARCHAR(255) PRIMARY KEY,
+    fingerprint CHAR(64)     NOT NULL,
+    status      INTEGER      NOT NULL,
+    body        BYTEA        NOT NULL,
+    created_at  TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
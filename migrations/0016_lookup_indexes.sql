@@ -0,0 +1,4 @@
+-- Indexes for the columns fetch filters on. serial_no already has the
+-- unique index from 0003.
+CREATE INDEX IF NOT EXISTS motors_party_name_idx ON motors (party_name);
+CREATE INDEX IF NOT EXISTS motors_dispatch_date_idx ON motors (dispatch_date);
@@ -0,0 +1,3 @@
+-- Dispatch staff look motors up by the LR or eway bill number on the
+-- shipping documents. Several motors can share one bill, so it isn't unique.
+CREATE INDEX IF NOT EXISTS motors_lr_or_eway_bill_idx ON motors (lr_or_eway_bill);
@@ -0,0 +1,14 @@
+-- Rewrite the common ways of writing a phase ("3", "Three Phase", "1-ph",
+-- ...) as single or three, matching normalizePhase. NOT VALID leaves any
+-- rows that still don't fit for someone to fix by hand, while every new or
+-- updated row has to pass.
+UPDATE motors SET phase = CASE regexp_replace(regexp_replace(lower(phase), '[-_ ]', '', 'g'), '(phase|ph|φ)$', '')
+    WHEN 'single' THEN 'single' WHEN '1' THEN 'single' WHEN 'one' THEN 'single' WHEN 'mono' THEN 'single'
+    WHEN 'three' THEN 'three' WHEN '3' THEN 'three' WHEN 'tri' THEN 'three' WHEN 'triple' THEN 'three'
+    WHEN 'poly' THEN 'three'
+    ELSE phase
+END
+WHERE phase NOT IN ('single', 'three');
+
+ALTER TABLE motors DROP CONSTRAINT IF EXISTS motors_phase_check;
+ALTER TABLE motors ADD CONSTRAINT motors_phase_check CHECK (phase IN ('single', 'three')) NOT VALID;
@@ -0,0 +1,12 @@
+-- Extended warranties bought for a motor, kept as a record of who extended
+-- it, when, and by how much
+CREATE TABLE IF NOT EXISTS warranty_extensions (
+    id                BIGSERIAL    PRIMARY KEY,
+    serial_no         VARCHAR(100) NOT NULL REFERENCES motors (serial_no),
+    months            INTEGER      NOT NULL CHECK (months > 0),
+    previous_end_date VARCHAR(10)  NOT NULL,
+    new_end_date      VARCHAR(10)  NOT NULL,
+    actor             TEXT         NOT NULL,
+    created_at        TIMESTAMPTZ  NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS warranty_extensions_serial_no_idx ON warranty_extensions (serial_no);
@@ -0,0 +1,20 @@
+-- Only live motors need unique serials, so a deleted motor's serial can be
+-- registered again. The partial index can't back a foreign key, so claims
+-- and warranty extensions point at the motor's id instead. Until now serials
+-- were unique across all rows, so the backfill matches at most one motor.
+ALTER TABLE claims ADD COLUMN IF NOT EXISTS motor_id BIGINT REFERENCES motors (id);
+UPDATE claims c SET motor_id = m.id FROM motors m WHERE m.serial_no = c.serial_no AND c.motor_id IS NULL;
+ALTER TABLE claims ALTER COLUMN motor_id SET NOT NULL;
+ALTER TABLE claims DROP CONSTRAINT IF EXISTS claims_serial_no_fkey;
+CREATE INDEX IF NOT EXISTS claims_motor_id_idx ON claims (motor_id);
+
+ALTER TABLE warranty_extensions ADD COLUMN IF NOT EXISTS motor_id BIGINT REFERENCES motors (id);
+UPDATE warranty_extensions e SET motor_id = m.id FROM motors m WHERE m.serial_no = e.serial_no AND e.motor_id IS NULL;
+ALTER TABLE warranty_extensions ALTER COLUMN motor_id SET NOT NULL;
+ALTER TABLE warranty_extensions DROP CONSTRAINT IF EXISTS warranty_extensions_serial_no_fkey;
+CREATE INDEX IF NOT EXISTS warranty_extensions_motor_id_idx ON warranty_extensions (motor_id);
+
+DROP INDEX IF EXISTS motors_serial_no_key;
+CREATE UNIQUE INDEX IF NOT EXISTS motors_live_serial_no_key ON motors (serial_no) WHERE deleted_at IS NULL;
+-- Lookups that include deleted motors still need an index on serial_no
+CREATE INDEX IF NOT EXISTS motors_serial_no_idx ON motors (serial_no);
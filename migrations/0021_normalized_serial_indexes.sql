@@ -0,0 +1,4 @@
+-- Serials are matched in their normalized form too, for rows stored before
+-- registration trimmed and uppercased them
+CREATE INDEX IF NOT EXISTS motors_normalized_serial_no_idx ON motors (upper(trim(serial_no)));
+CREATE INDEX IF NOT EXISTS audit_log_normalized_serial_no_idx ON audit_log (upper(trim(serial_no)));
@@ -0,0 +1,14 @@
+-- Postgres checks a NOT VALID constraint on every later update of a row, so
+-- a legacy phase 0018 couldn't map left its motor impossible to update or
+-- even delete. Such rows are set aside instead: the original text moves to
+-- phase_raw and phase is left empty, which the constraint allows only
+-- alongside a phase_raw. Updating the motor with a real phase clears it up.
+-- To find them: SELECT serial_no, phase_raw FROM motors WHERE phase = ''.
+ALTER TABLE motors ADD COLUMN IF NOT EXISTS phase_raw VARCHAR(20);
+
+UPDATE motors SET phase_raw = phase, phase = ''
+WHERE phase NOT IN ('single', 'three');
+
+ALTER TABLE motors DROP CONSTRAINT IF EXISTS motors_phase_check;
+ALTER TABLE motors ADD CONSTRAINT motors_phase_check
+    CHECK (phase IN ('single', 'three') OR (phase = '' AND phase_raw IS NOT NULL));
@@ -0,0 +1,12 @@
+-- Dates are stored as text, and a string shaped like a date can still be
+-- impossible (2024-02-30), which fails a plain ::date cast and with it the
+-- whole query. safe_date gives NULL for those instead.
+CREATE OR REPLACE FUNCTION safe_date(s TEXT) RETURNS DATE LANGUAGE plpgsql STABLE AS $$
+BEGIN
+    IF s !~ '^\d{4}-\d{2}-\d{2}$' THEN
+        RETURN NULL;
+    END IF;
+    RETURN s::date;
+EXCEPTION WHEN datetime_field_overflow OR invalid_datetime_format THEN
+    RETURN NULL;
+END $$;
@@ -0,0 +1,8 @@
+-- Idempotency keys belong to the caller that sent them, so another caller
+-- reusing a key neither gets the saved response nor learns the key is taken.
+-- Saved responses only last a day, so the unscoped ones are dropped rather
+-- than guessed an owner.
+DELETE FROM idempotency_keys;
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS actor VARCHAR(255) NOT NULL;
+ALTER TABLE idempotency_keys DROP CONSTRAINT IF EXISTS idempotency_keys_pkey;
+ALTER TABLE idempotency_keys ADD PRIMARY KEY (actor, key);
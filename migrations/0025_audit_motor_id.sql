@@ -0,0 +1,15 @@
+-- A serial can be registered again once its motor is deleted, so audit
+-- entries are tied to the motor they describe. Each payload holds the
+-- motor's id; entries written before 0004 gave motors one fall back to the
+-- serial, which was unique then. Entries whose motor can't be found keep a
+-- NULL motor_id and still show up in /audit.
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS motor_id BIGINT REFERENCES motors (id);
+
+UPDATE audit_log a SET motor_id = m.id
+FROM motors m
+WHERE a.motor_id IS NULL AND a.payload ->> 'id' ~ '^[1-9][0-9]*$' AND m.id = (a.payload ->> 'id')::BIGINT;
+
+UPDATE audit_log a SET motor_id = (SELECT min(m.id) FROM motors m WHERE m.serial_no = a.serial_no)
+WHERE a.motor_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS audit_log_motor_id_idx ON audit_log (motor_id, created_at);
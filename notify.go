@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// mailer sends registration confirmations over SMTP
+type mailer struct {
+	addr     string
+	auth     smtp.Auth
+	from     string
+	fallback string // recipient when the motor has no party_email
+}
+
+// newMailerFromEnv configures a mailer from SMTP_HOST, SMTP_PORT (587 by
+// default), SMTP_USER, SMTP_PASSWORD, SMTP_FROM and SMTP_TO. It returns nil,
+// turning notifications off, when SMTP_HOST isn't set.
+func newMailerFromEnv() *mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return nil
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	m := &mailer{
+		addr:     net.JoinHostPort(host, port),
+		from:     os.Getenv("SMTP_FROM"),
+		fallback: os.Getenv("SMTP_TO"),
+	}
+	if user := os.Getenv("SMTP_USER"); user != "" {
+		m.auth = smtp.PlainAuth("", user, os.Getenv("SMTP_PASSWORD"), host)
+		if m.from == "" {
+			m.from = user
+		}
+	}
+	return m
+}
+
+// notifyRegistered emails the warranty details of a newly registered motor
+// to its party, or to SMTP_TO when it has no address. It returns straight
+// away; failures are only logged since the motor is already stored.
+func (m *mailer) notifyRegistered(motor Motor, requestID string) {
+	to := motor.PartyEmail
+	if to == "" {
+		to = m.fallback
+	}
+	if to == "" {
+		return
+	}
+
+	go func() {
+		if err := smtp.SendMail(m.addr, m.auth, m.from, []string{to}, registrationMessage(m.from, to, motor)); err != nil {
+			slog.Error("Registration email failed", "request_id", requestID, "serial_no", motor.SerialNo, "error", err)
+		}
+	}()
+}
+
+// registrationMessage builds the confirmation email for motor
+func registrationMessage(from, to string, motor Motor) []byte {
+	end := motor.WarrantyEndDate
+	if end == "" {
+		end = "Not set"
+	}
+	// Keep client-supplied values from adding header lines
+	oneLine := strings.NewReplacer("\r", " ", "\n", " ")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: Warranty registered for motor %s\r\n", oneLine.Replace(motor.SerialNo))
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&b, "Your motor has been registered for warranty.\r\n\r\n")
+	fmt.Fprintf(&b, "Serial number:  %s\r\n", motor.SerialNo)
+	fmt.Fprintf(&b, "Model:          %s\r\n", motor.MotorModel)
+	fmt.Fprintf(&b, "Party:          %s\r\n", motor.PartyName)
+	fmt.Fprintf(&b, "Dispatch date:  %s\r\n", motor.DispatchDate)
+	fmt.Fprintf(&b, "Warranty start: %s\r\n", motor.WarrantyStartDate)
+	fmt.Fprintf(&b, "Warranty end:   %s\r\n", end)
+	return []byte(b.String())
+}
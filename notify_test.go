@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistrationMessageHeaders(t *testing.T) {
+	motor := Motor{SerialNo: "SN1\r\nBcc: someone@example.com", WarrantyEndDate: ""}
+	msg := string(registrationMessage("from@example.com", "to@example.com", motor))
+
+	headers, body, ok := strings.Cut(msg, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator: %q", msg)
+	}
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("serial number injected a header: %q", headers)
+		}
+	}
+	if !strings.Contains(body, "Warranty end:   Not set") {
+		t.Errorf("body does not report the missing end date: %q", body)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// routeDoc describes an endpoint for the OpenAPI spec. Paths and methods
+// come from the router itself, so only the prose lives here.
+type routeDoc struct {
+	Summary     string
+	QueryParams []string
+	Body        string // name of the request body schema, if any
+	Response    string // name of the success response schema, if any
+	Status      int    // success status, 200 when unset
+	Errors      []int
+	Auth        bool
+}
+
+// Docs keyed by "METHOD /path/template". Routes without an entry are still
+// listed, just without a summary.
+var routeDocs = map[string]routeDoc{
+	"GET /fetch": {
+		Summary: "List motors matching the given filters; mode=cursor pages by id using after and next_cursor, " +
+			"and fields=a,b returns only those fields plus serial_no",
+		QueryParams: []string{"serial_no", "party_name", "search", "motor_model", "phase",
+			"transport_agency", "lr_eway_bill", "dispatch_from", "dispatch_to", "min_kw", "max_kw", "min_rpm", "max_rpm",
+			"sort_by", "order", "limit", "offset", "include_deleted", "mode", "after", "fields"},
+		Response: "MotorPage",
+		Errors:   []int{400, 403, 404, 500},
+	},
+	"POST /fetch/query": {
+		Summary:     "List motors matching a JSON filter of field/op/value conditions grouped with and/or",
+		QueryParams: []string{"sort_by", "order", "limit", "offset", "include_deleted"},
+		Response:    "MotorPage", Errors: []int{400, 403, 404, 413, 500},
+	},
+	"GET /schema/motor": {Summary: "Get a JSON Schema for Motor, with required fields matching register's validation"},
+	"POST /fetch/batch": {
+		Summary: `Get the motors for a body of {"serial_nos": [...]}, up to 500, listing serials with no motor in not_found`,
+		Errors:  []int{400, 413, 500},
+	},
+	"GET /admin/maintenance": {Summary: "Report whether maintenance mode is turning away writes"},
+	"PUT /admin/maintenance": {
+		Summary: `Switch maintenance mode with {"enabled": true|false}; while on, writes get 503 {"status": "maintenance"}`,
+		Errors:  []int{400, 401, 403}, Auth: true,
+	},
+	"GET /count": {Summary: "Count motors, optionally for one party", QueryParams: []string{"party_name"}},
+	"GET /stats": {Summary: "Dashboard totals by phase, warranty status and recent dispatch", Errors: []int{500}},
+	"GET /report": {
+		Summary: "Count motors dispatched per period (week, month, quarter or year) and per party, model, phase " +
+			"or transport_agency, with each group's warranty status",
+		QueryParams: []string{"group_by", "period", "dispatch_from", "dispatch_to"}, Errors: []int{400, 500},
+	},
+	"GET /lookup": {
+		Summary: "Look up motors by q as an exact serial_no, else a party_name containing it, else an exact " +
+			"lr_eway_bill; matched_by says which",
+		QueryParams: []string{"q", "limit", "offset", "sort_by", "order"}, Errors: []int{400, 404, 500},
+	},
+	"GET /expiring": {
+		Summary: "List motors whose warranty ends within the next days days (default 30), at most MAX_RESULT_ROWS " +
+			"of them; truncated and the X-Result-Truncated header say when more matched",
+		QueryParams: []string{"days"},
+		Errors:      []int{400, 500},
+	},
+	"GET /parties": {
+		Summary: "List distinct party names with motor counts, optionally by name prefix", QueryParams: []string{"q"},
+		Errors: []int{500},
+	},
+	"GET /transport-agencies": {
+		Summary: "List distinct transport agencies with motor counts, optionally by name prefix", QueryParams: []string{"q"},
+		Errors: []int{500},
+	},
+	"GET /search": {
+		Summary:     "Search serial, model, party, address and transport agency, best matches first",
+		QueryParams: []string{"q", "limit", "offset"}, Errors: []int{400, 500},
+	},
+	"GET /export/csv": {Summary: "Download motors matching the fetch filters as CSV"},
+	"GET /audit":      {Summary: "List a motor's audit trail", QueryParams: []string{"serial_no"}},
+	"GET /motor/id/{id}": {
+		Summary: "Get a motor by id", Response: "Motor", Errors: []int{400, 404, 500},
+	},
+	"GET /motor/{serial_no}": {
+		Summary:  "Get a motor by serial number; answers 304 when If-None-Match holds its ETag",
+		Response: "Motor", Errors: []int{404, 500},
+	},
+	"GET /motor/{serial_no}/certificate": {Summary: "Download a PDF warranty certificate", Errors: []int{404}},
+	"GET /motor/{serial_no}/certificate/download": {
+		Summary: "Download the uploaded test certificate scan", Errors: []int{404, 500},
+	},
+	"POST /motor/{serial_no}/certificate/upload": {
+		Summary: "Upload a PDF, JPEG or PNG test certificate as the multipart field file", Status: 201,
+		Errors: []int{400, 401, 404, 413, 415, 500}, Auth: true,
+	},
+	"GET /motor/{serial_no}/qr": {Summary: "Get a PNG QR code linking to the motor", Errors: []int{404}},
+	"POST /motor/{serial_no}/extend": {
+		Summary: `Extend a motor's warranty by {"months": n}; an expired one restarts from today and needs "allow_expired": true`,
+		Status:  201, Errors: []int{400, 401, 404, 500}, Auth: true,
+	},
+	"GET /motor/{serial_no}/history": {
+		Summary: "List a motor's changes oldest first, each with the fields it changed as from/to pairs",
+		Errors:  []int{404, 500},
+	},
+	"GET /motor/{serial_no}/claims": {Summary: "List a motor's warranty claims", Errors: []int{404}},
+	"POST /register": {
+		Summary: "Register a motor; retries by the same caller with the same Idempotency-Key header get the first response. " +
+			"dry_run=true only checks it, answering 200 if it's valid or 422 listing the problems",
+		QueryParams: []string{"dry_run"},
+		Body:        "Motor", Response: "Motor", Status: 201,
+		Errors: []int{400, 401, 409, 413, 422, 500}, Auth: true,
+	},
+	"POST /import/csv": {
+		Summary:     "Register motors from an uploaded CSV with a header row; bad rows are reported, or fail the import with strict=true",
+		QueryParams: []string{"strict"}, Errors: []int{400, 401, 413, 422, 500}, Auth: true,
+	},
+	"POST /register/bulk": {
+		Summary: "Register a batch of motors in one transaction", Body: "MotorList", Status: 201,
+		Errors: []int{400, 401, 409, 500}, Auth: true,
+	},
+	"PUT /motors/bulk-update": {
+		Summary: "Set party_name, party_address, party_email, transport_agency or remarks on every motor " +
+			"matching the fetch filters, which may not all be empty; answers with the number updated",
+		QueryParams: []string{"serial_no", "party_name", "search", "motor_model", "phase",
+			"transport_agency", "lr_eway_bill", "dispatch_from", "dispatch_to", "min_kw", "max_kw", "min_rpm", "max_rpm"},
+		Errors: []int{400, 401, 413, 500}, Auth: true,
+	},
+	"PUT /update/{serial_no}": {
+		Summary: "Update a motor; fields left out keep their values and version must be the one read",
+		Body:    "Motor", Response: "Motor", Errors: []int{400, 401, 404, 409, 500}, Auth: true,
+	},
+	"PATCH /motor/{serial_no}": {
+		Summary: "Change only the fields sent; version must be the one read",
+		Body:    "Motor", Response: "Motor", Errors: []int{400, 401, 404, 409, 413, 500}, Auth: true,
+	},
+	"DELETE /motor/{serial_no}": {
+		Summary: "Soft-delete a motor", Errors: []int{401, 404, 500}, Auth: true,
+	},
+	"POST /motor/{serial_no}/claims": {
+		Summary: "File a warranty claim", Status: 201, Errors: []int{400, 401, 404, 500}, Auth: true,
+	},
+	"PATCH /claims/{id}/status": {
+		Summary: "Move a claim to a new status", Errors: []int{400, 401, 404, 409, 500}, Auth: true,
+	},
+	"GET /warranty-policies": {
+		Summary: "List warranty policies", Response: "WarrantyPolicyList", Errors: []int{500},
+	},
+	"POST /warranty-policies": {
+		Summary: "Add a warranty policy for a phase and/or model (admin only)", Body: "WarrantyPolicy",
+		Response: "WarrantyPolicy", Status: 201, Errors: []int{400, 401, 403, 409, 500}, Auth: true,
+	},
+	"PUT /warranty-policies/{id}": {
+		Summary: "Replace a warranty policy (admin only)", Body: "WarrantyPolicy",
+		Response: "WarrantyPolicy", Errors: []int{400, 401, 403, 404, 409, 500}, Auth: true,
+	},
+	"DELETE /warranty-policies/{id}": {
+		Summary: "Delete a warranty policy (admin only)", Errors: []int{400, 401, 403, 404, 500}, Auth: true,
+	},
+}
+
+// Strips mux's regexp constraints, e.g. {id:[0-9]+} becomes {id}
+var routeVarPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIHandler serves an OpenAPI 3 description of every route registered
+// on router, whose paths all start with basePath
+func openAPIHandler(router *mux.Router, basePath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildOpenAPI(router, basePath))
+	}
+}
+
+func buildOpenAPI(router *mux.Router, basePath string) map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		path := routeVarPattern.ReplaceAllString(strings.TrimPrefix(tmpl, basePath), "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		for _, method := range methods {
+			paths[path][strings.ToLower(method)] = openAPIOperation(method, path)
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Warranty Software API",
+			"version": "1.0.0",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": basePath}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Motor": motorSchema(),
+				"Error": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"error":  map[string]interface{}{"type": "string"},
+						"status": map[string]interface{}{"type": "integer"},
+					},
+				},
+				"MotorList":          map[string]interface{}{"type": "array", "items": schemaRef("Motor")},
+				"WarrantyPolicy":     structSchema(reflect.TypeOf(WarrantyPolicy{})),
+				"WarrantyPolicyList": map[string]interface{}{"type": "array", "items": schemaRef("WarrantyPolicy")},
+				"MotorPage": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"data":   map[string]interface{}{"type": "array", "items": schemaRef("Motor")},
+						"total":  map[string]interface{}{"type": "integer"},
+						"limit":  map[string]interface{}{"type": "integer"},
+						"offset": map[string]interface{}{"type": "integer"},
+						// Set instead of total and offset with mode=cursor
+						"next_cursor": map[string]interface{}{"type": "string", "nullable": true},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+func openAPIOperation(method, path string) map[string]interface{} {
+	doc := routeDocs[method+" "+path]
+	op := map[string]interface{}{}
+	if doc.Summary != "" {
+		op["summary"] = doc.Summary
+	}
+
+	var params []interface{}
+	for _, m := range routeVarPattern.FindAllStringSubmatch(path, -1) {
+		params = append(params, map[string]interface{}{
+			"name": m[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, name := range doc.QueryParams {
+		params = append(params, map[string]interface{}{
+			"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Body != "" {
+		op["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef(doc.Body)}},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	if doc.Response != "" {
+		success["content"] = map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef(doc.Response)}}
+	}
+	responses := map[string]interface{}{strconv.Itoa(status): success}
+	for _, code := range doc.Errors {
+		responses[strconv.Itoa(code)] = map[string]interface{}{
+			"description": http.StatusText(code),
+			"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": schemaRef("Error")}},
+		}
+	}
+	op["responses"] = responses
+
+	if doc.Auth {
+		op["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+	return op
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+}
+
+// structSchema describes a struct's JSON encoding, taking property names from
+// its json tags
+func structSchema(t reflect.Type) map[string]interface{} {
+	props := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		props[name] = typeSchema(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// listParties returns the distinct party names of live motors with how many
+// motors each has. q narrows the list to names starting with it, ignoring
+// case, for typeahead.
+var listParties = listDistinct("party_name", "parties")
+
+// listTransportAgencies returns the distinct transport agencies of live
+// motors with their motor counts, narrowed by q as for listParties
+var listTransportAgencies = listDistinct("transport_agency", "transport agencies")
+
+// listDistinct returns a handler listing the distinct non-empty values of
+// column across live motors, each with its motor count. column must be a
+// trusted column name; it goes into the SQL as is.
+func listDistinct(column, noun string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filters := map[string]interface{}{"deleted_at IS NULL": nil, column + " <> ''": nil}
+		if prefix := strings.TrimSpace(r.URL.Query().Get("q")); prefix != "" {
+			filters[column+` ILIKE $%d || '%%'`] = escapeLike(prefix)
+		}
+		where, args := buildWhere(filters)
+
+		rows, err := db.QueryContext(r.Context(),
+			"SELECT "+column+", COUNT(*) FROM motors "+where+" GROUP BY "+column+" ORDER BY "+column, args...)
+		if err != nil {
+			dbError(w, r, "Error fetching "+noun+": "+err.Error())
+			return
+		}
+		defer rows.Close()
+
+		values := []map[string]interface{}{}
+		for rows.Next() {
+			var value string
+			var count int
+			if err := rows.Scan(&value, &count); err != nil {
+				dbError(w, r, "Error scanning row: "+err.Error())
+				return
+			}
+			values = append(values, map[string]interface{}{column: value, "count": count})
+		}
+		if err := rows.Err(); err != nil {
+			dbError(w, r, "Error fetching "+noun+": "+err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": values})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// patchField is a motor field a PATCH may set: the column it's stored in and
+// how to read its value off a Motor
+type patchField struct {
+	column string
+	value  func(Motor) interface{}
+}
+
+// Fields PATCH may set, keyed by JSON name
+var patchableFields = map[string]patchField{
+	"motor_model":         {"motor_model", func(m Motor) interface{} { return m.MotorModel }},
+	"rpm":                 {"rpm", func(m Motor) interface{} { return m.RPM }},
+	"phase":               {"phase", func(m Motor) interface{} { return m.Phase }},
+	"party_name":          {"party_name", func(m Motor) interface{} { return m.PartyName }},
+	"dispatch_date":       {"dispatch_date", func(m Motor) interface{} { return m.DispatchDate }},
+	"transport_agency":    {"transport_agency", func(m Motor) interface{} { return m.TransportAgency }},
+	"lr_eway_bill":        {"lr_or_eway_bill", func(m Motor) interface{} { return m.LREwayBill }},
+	"test_certificate":    {"test_certificate", func(m Motor) interface{} { return m.TestCertificate }},
+	"party_address":       {"party_address", func(m Motor) interface{} { return m.PartyAddress }},
+	"hp_kw":               {"hp_kw", func(m Motor) interface{} { return m.HPKW }},
+	"remarks":             {"remarks", func(m Motor) interface{} { return m.Remarks }},
+	"warranty_start_date": {"warranty_start_date", func(m Motor) interface{} { return m.WarrantyStartDate }},
+	"warranty_end_date":   {"warranty_end_date", func(m Motor) interface{} { return m.WarrantyEndDate }},
+	"party_email":         {"party_email", func(m Motor) interface{} { return m.PartyEmail }},
+}
+
+// Fields that identify a motor or are maintained by the server
+var immutableFields = map[string]bool{
+	"id":         true,
+	"serial_no":  true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// patchMotor updates only the fields present in the request body. Like a
+// PUT, it must name the version the client last read.
+func (s *server) patchMotor(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
+	var patch map[string]json.RawMessage
+	err := json.NewDecoder(r.Body).Decode(&patch)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
+
+	var version int64
+	raw, ok := patch["version"]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "version is required")
+		return
+	}
+	if err := json.Unmarshal(raw, &version); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "version must be an integer")
+		return
+	}
+	delete(patch, "version")
+
+	fields := make([]string, 0, len(patch))
+	for key := range patch {
+		if immutableFields[key] {
+			writeJSONError(w, http.StatusBadRequest, key+" can't be changed")
+			return
+		}
+		if _, ok := patchableFields[key]; !ok {
+			writeJSONError(w, http.StatusBadRequest, `unknown field "`+key+`"`)
+			return
+		}
+		fields = append(fields, key)
+	}
+	if len(fields) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "No fields to update")
+		return
+	}
+	sort.Strings(fields)
+
+	// Apply the patch over the stored record so the result can be validated
+	// as a whole
+	motor, err := s.store.GetBySerial(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+	stored := motor.SerialNo
+	for _, key := range fields {
+		body, _ := json.Marshal(map[string]json.RawMessage{key: patch[key]})
+		if err := json.Unmarshal(body, &motor); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid value for "+key)
+			return
+		}
+	}
+	normalizeMotor(&motor)
+	motor.SerialNo = stored
+	motor.Version = version
+	if err := validateMotor(motor); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updated, err := s.store.Patch(r.Context(), motor, fields)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err == ErrVersionConflict {
+		writeJSONError(w, http.StatusConflict, "Motor was changed by another request; reload it and try again")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error updating data")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(motorResponse(updated))
+}
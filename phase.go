@@ -0,0 +1,28 @@
+package main
+
+import "strings"
+
+// Ways people write each phase once lowercased and stripped of a trailing
+// "phase" or "ph", e.g. "3-Phase", "Three Phase", "1ph"
+var phaseVariants = map[string]string{
+	"single": "single", "1": "single", "one": "single", "mono": "single",
+	"three": "three", "3": "three", "tri": "three", "triple": "three", "poly": "three",
+}
+
+// normalizePhase maps the common ways of writing a phase onto "single" or
+// "three". Anything it doesn't recognize comes back unchanged, for
+// validateMotor to reject.
+func normalizePhase(phase string) string {
+	s := strings.ToLower(strings.TrimSpace(phase))
+	s = strings.NewReplacer("-", "", "_", "", " ", "").Replace(s)
+	for _, suffix := range []string{"phase", "ph", "φ"} {
+		if trimmed := strings.TrimSuffix(s, suffix); trimmed != s {
+			s = trimmed
+			break
+		}
+	}
+	if canonical, ok := phaseVariants[s]; ok {
+		return canonical
+	}
+	return phase
+}
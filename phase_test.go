@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestNormalizePhase(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"three", "three"},
+		{"Three Phase", "three"},
+		{"3", "three"},
+		{"3-phase", "three"},
+		{" 3PH ", "three"},
+		{"3φ", "three"},
+		{"single", "single"},
+		{"Single-Phase", "single"},
+		{"1 ph", "single"},
+		{"2", "2"},
+		{"split phase", "split phase"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizePhase(tt.in); got != tt.want {
+			t.Errorf("normalizePhase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// WarrantyPolicy sets the warranty period for motors of a phase and/or
+// model. An empty Phase or MotorModel matches any motor.
+type WarrantyPolicy struct {
+	ID         int64     `json:"id"`
+	Phase      string    `json:"phase"`
+	MotorModel string    `json:"motor_model"`
+	Months     int       `json:"months"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// policyColumns lists the warranty_policies columns in the order scanPolicy
+// reads them
+const policyColumns = `id, phase, motor_model, months, created_at, updated_at`
+
+// Longest warranty a policy may grant
+const maxPolicyMonths = 120
+
+func scanPolicy(row rowScanner) (WarrantyPolicy, error) {
+	var p WarrantyPolicy
+	err := row.Scan(&p.ID, &p.Phase, &p.MotorModel, &p.Months, &p.CreatedAt, &p.UpdatedAt)
+	return p, err
+}
+
+// policyMonths returns the warranty period of the most specific policy
+// matching phase and model: model and phase, then model, then phase, then
+// the global default. Without any policy it's defaultWarrantyMonths.
+func policyMonths(ctx context.Context, phase, model string) (int, error) {
+	var months int
+	err := db.QueryRowContext(ctx,
+		`SELECT months FROM warranty_policies WHERE phase IN ('', $1) AND motor_model IN ('', $2)
+         ORDER BY motor_model <> '' DESC, phase <> '' DESC LIMIT 1`, phase, model).Scan(&months)
+	if err == sql.ErrNoRows {
+		return defaultWarrantyMonths, nil
+	}
+	return months, err
+}
+
+// applyWarrantyPolicy fills in m's missing warranty dates, taking the period
+// from its warranty policy. The in-memory store has no policies, so it always
+// gets defaultWarrantyMonths.
+func applyWarrantyPolicy(ctx context.Context, m *Motor) error {
+	months := defaultWarrantyMonths
+	if m.WarrantyEndDate == "" && db != nil {
+		var err error
+		if months, err = policyMonths(ctx, m.Phase, m.MotorModel); err != nil {
+			return err
+		}
+	}
+	fillWarrantyDates(m, months)
+	return nil
+}
+
+// validatePolicy checks a policy from a client, normalizing its fields
+func validatePolicy(p *WarrantyPolicy) string {
+	p.Phase = normalizePhase(strings.TrimSpace(p.Phase))
+	p.MotorModel = strings.TrimSpace(p.MotorModel)
+	if p.Phase != "" && p.Phase != "single" && p.Phase != "three" {
+		return `phase must be "single", "three" or empty`
+	}
+	if p.Months <= 0 || p.Months > maxPolicyMonths {
+		return "months must be between 1 and " + strconv.Itoa(maxPolicyMonths)
+	}
+	return ""
+}
+
+// decodePolicy reads and validates a policy from the request body, writing
+// the error response itself when that fails
+func decodePolicy(w http.ResponseWriter, r *http.Request) (WarrantyPolicy, bool) {
+	if !isAdmin(r) {
+		writeJSONError(w, http.StatusForbidden, "Changing warranty policies requires an admin token")
+		return WarrantyPolicy{}, false
+	}
+	var p WarrantyPolicy
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid input")
+		return WarrantyPolicy{}, false
+	}
+	if msg := validatePolicy(&p); msg != "" {
+		writeJSONError(w, http.StatusBadRequest, msg)
+		return WarrantyPolicy{}, false
+	}
+	return p, true
+}
+
+// listPolicies returns every warranty policy, most general first
+func listPolicies(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT "+policyColumns+" FROM warranty_policies ORDER BY motor_model, phase")
+	if err != nil {
+		dbError(w, r, "Error fetching policies: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	policies := []WarrantyPolicy{}
+	for rows.Next() {
+		p, err := scanPolicy(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		policies = append(policies, p)
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching policies: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies)
+}
+
+func createPolicy(w http.ResponseWriter, r *http.Request) {
+	p, ok := decodePolicy(w, r)
+	if !ok {
+		return
+	}
+
+	stored, err := scanPolicy(db.QueryRowContext(r.Context(),
+		`INSERT INTO warranty_policies (phase, motor_model, months) VALUES ($1, $2, $3)
+         RETURNING `+policyColumns, p.Phase, p.MotorModel, p.Months))
+	if isUniqueViolation(err) {
+		writeJSONError(w, http.StatusConflict, "A policy for that phase and model already exists")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error inserting policy")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(stored)
+}
+
+func updatePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	p, ok := decodePolicy(w, r)
+	if !ok {
+		return
+	}
+
+	updated, err := scanPolicy(db.QueryRowContext(r.Context(),
+		`UPDATE warranty_policies SET phase = $2, motor_model = $3, months = $4, updated_at = now()
+         WHERE id = $1 RETURNING `+policyColumns, id, p.Phase, p.MotorModel, p.Months))
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Policy not found")
+		return
+	}
+	if isUniqueViolation(err) {
+		writeJSONError(w, http.StatusConflict, "A policy for that phase and model already exists")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error updating policy")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// deletePolicy removes a policy. Motors already registered keep the dates
+// it gave them.
+func deletePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid id")
+		return
+	}
+	if !isAdmin(r) {
+		writeJSONError(w, http.StatusForbidden, "Changing warranty policies requires an admin token")
+		return
+	}
+
+	res, err := db.ExecContext(r.Context(), "DELETE FROM warranty_policies WHERE id = $1", id)
+	if err != nil {
+		dbError(w, r, "Error deleting policy")
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeJSONError(w, http.StatusNotFound, "Policy not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Policy deleted"})
+}
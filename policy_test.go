@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestValidatePolicy(t *testing.T) {
+	tests := []struct {
+		policy  WarrantyPolicy
+		wantErr bool
+	}{
+		{WarrantyPolicy{Months: 12}, false},
+		{WarrantyPolicy{Phase: " three ", Months: 24}, false},
+		{WarrantyPolicy{Phase: "three", MotorModel: "MX-100", Months: 36}, false},
+		{WarrantyPolicy{Phase: "two", Months: 12}, true},
+		{WarrantyPolicy{Months: 0}, true},
+		{WarrantyPolicy{Months: maxPolicyMonths + 1}, true},
+	}
+	for _, tt := range tests {
+		p := tt.policy
+		if msg := validatePolicy(&p); (msg != "") != tt.wantErr {
+			t.Errorf("validatePolicy(%+v) = %q, wantErr %v", tt.policy, msg, tt.wantErr)
+		}
+	}
+}
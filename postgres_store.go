@@ -0,0 +1,386 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// PostgresStore is the MotorStore backed by the motors table. Every write
+// records an audit_log entry in the same transaction.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+func newPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// The exported methods retry the unexported ones that do the work when
+// Postgres fails in a way that's likely to pass; see withRetry
+
+func (s *PostgresStore) Register(ctx context.Context, motor Motor) (stored Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		stored, err = s.register(ctx, motor)
+		return err
+	})
+	return stored, err
+}
+
+func (s *PostgresStore) RegisterBatch(ctx context.Context, motors []Motor) (stored []Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		stored, err = s.registerBatch(ctx, motors)
+		return err
+	})
+	return stored, err
+}
+
+func (s *PostgresStore) Fetch(ctx context.Context, q MotorQuery) (motors []Motor, total int, err error) {
+	err = withRetry(ctx, func() (err error) {
+		motors, total, err = s.fetch(ctx, q)
+		return err
+	})
+	return motors, total, err
+}
+
+func (s *PostgresStore) GetBySerial(ctx context.Context, serial string) (motor Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		motor, err = s.getBySerial(ctx, serial)
+		return err
+	})
+	return motor, err
+}
+
+func (s *PostgresStore) GetBySerials(ctx context.Context, serials []string) (motors []Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		motors, err = s.getBySerials(ctx, serials)
+		return err
+	})
+	return motors, err
+}
+
+func (s *PostgresStore) FindSimilar(ctx context.Context, serial string) (motor Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		motor, err = s.findSimilar(ctx, serial)
+		return err
+	})
+	return motor, err
+}
+
+func (s *PostgresStore) Update(ctx context.Context, motor Motor) (updated Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		updated, err = s.update(ctx, motor)
+		return err
+	})
+	return updated, err
+}
+
+func (s *PostgresStore) Patch(ctx context.Context, motor Motor, fields []string) (updated Motor, err error) {
+	err = withRetry(ctx, func() (err error) {
+		updated, err = s.patch(ctx, motor, fields)
+		return err
+	})
+	return updated, err
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, serial string) error {
+	return withRetry(ctx, func() error {
+		return s.delete(ctx, serial)
+	})
+}
+
+func (s *PostgresStore) register(ctx context.Context, motor Motor) (Motor, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Motor{}, err
+	}
+	defer tx.Rollback()
+
+	stored, err := insertMotor(ctx, tx, motor)
+	if isUniqueViolation(err) {
+		return Motor{}, ErrDuplicateSerial
+	}
+	if err == nil {
+		err = writeAudit(ctx, tx, "register", stored)
+	}
+	if err == nil {
+		err = commitTx(tx)
+	}
+	return stored, err
+}
+
+func (s *PostgresStore) registerBatch(ctx context.Context, motors []Motor) ([]Motor, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stored := make([]Motor, 0, len(motors))
+	for i, motor := range motors {
+		m, err := insertMotor(ctx, tx, motor)
+		if isUniqueViolation(err) {
+			return nil, &BatchError{Index: i, Err: ErrDuplicateSerial}
+		}
+		if err == nil {
+			err = writeAudit(ctx, tx, "register", m)
+		}
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		stored = append(stored, m)
+	}
+	return stored, commitTx(tx)
+}
+
+func (s *PostgresStore) fetch(ctx context.Context, q MotorQuery) ([]Motor, int, error) {
+	filters := q.Filter.conditions()
+	if !q.Filter.IncludeDeleted {
+		filters["deleted_at IS NULL"] = nil
+	}
+	if q.Cursor {
+		return s.fetchAfter(ctx, filters, q)
+	}
+	columns := selectColumns(q.Fields)
+	query, queryArgs, err := buildFetchQuery(columns, filters, q.Sort.clause(), q.Limit, q.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Count the full match set so clients can page through it
+	where, args := buildWhere(filters)
+	var total int
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM motors "+where, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var motors []Motor
+	for rows.Next() {
+		motor, err := scanMotorColumns(rows, columns)
+		if err != nil {
+			return nil, 0, err
+		}
+		motors = append(motors, motor)
+	}
+	// A connection dropped mid-result ends Next early; don't pass off the
+	// partial page as complete
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	return motors, total, nil
+}
+
+// fetchAfter reads a page in cursor mode. Keying on id means rows inserted
+// meanwhile can't shift the pages, unlike an offset.
+func (s *PostgresStore) fetchAfter(ctx context.Context, filters map[string]interface{}, q MotorQuery) ([]Motor, int, error) {
+	filters["id > $%d"] = q.After
+	where, args := buildWhere(filters)
+	columns := selectColumns(q.Fields)
+	rows, err := s.db.QueryContext(ctx,
+		fmt.Sprintf("SELECT %s FROM motors %s ORDER BY id LIMIT $%d", strings.Join(columns, ", "), where, len(args)+1),
+		append(args, q.Limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var motors []Motor
+	for rows.Next() {
+		motor, err := scanMotorColumns(rows, columns)
+		if err != nil {
+			return nil, 0, err
+		}
+		motors = append(motors, motor)
+	}
+	return motors, 0, rows.Err()
+}
+
+func (s *PostgresStore) getBySerial(ctx context.Context, serial string) (Motor, error) {
+	motor, err := findMotor(ctx, s.db, serial)
+	if err == sql.ErrNoRows {
+		return Motor{}, ErrNotFound
+	}
+	return motor, err
+}
+
+// getBySerials looks all the serials up in a single query
+func (s *PostgresStore) getBySerials(ctx context.Context, serials []string) ([]Motor, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+motorSelectColumns+` FROM motors 
+         WHERE (serial_no = ANY($1) OR upper(trim(serial_no)) = ANY($1)) AND deleted_at IS NULL`,
+		pq.Array(serials))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var motors []Motor
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			return nil, err
+		}
+		motors = append(motors, motor)
+	}
+	return motors, rows.Err()
+}
+
+func (s *PostgresStore) findSimilar(ctx context.Context, serial string) (Motor, error) {
+	return findSimilarMotor(ctx, s.db, serial)
+}
+
+// findSimilarMotor returns a live motor whose serial normalizes to the same
+// value as serial, or ErrNotFound
+func findSimilarMotor(ctx context.Context, q querier, serial string) (Motor, error) {
+	motor, err := scanMotor(q.QueryRowContext(ctx,
+		"SELECT "+motorSelectColumns+" FROM motors WHERE upper(trim(serial_no)) = $1 AND deleted_at IS NULL LIMIT 1",
+		normalizeSerial(serial)))
+	if err == sql.ErrNoRows {
+		return Motor{}, ErrNotFound
+	}
+	return motor, err
+}
+
+func (s *PostgresStore) update(ctx context.Context, motor Motor) (Motor, error) {
+	query := `UPDATE motors SET motor_model = $2, rpm = $3, phase = $4, party_name = $5, dispatch_date = $6, 
+              transport_agency = $7, lr_or_eway_bill = $8, test_certificate = $9, party_address = $10, 
+              hp_kw = $11, remarks = $12, warranty_start_date = $13, warranty_end_date = $14, 
+              party_email = $15, power_kw = $16, updated_at = now(), version = version + 1 
+              WHERE ` + liveSerialMatch + ` AND version = $17 
+              RETURNING ` + motorSelectColumns
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Motor{}, err
+	}
+	defer tx.Rollback()
+
+	updated, err := scanMotor(tx.QueryRowContext(ctx, query, motor.SerialNo, motor.MotorModel, motor.RPM,
+		motor.Phase, motor.PartyName, motor.DispatchDate, motor.TransportAgency, motor.LREwayBill,
+		motor.TestCertificate, motor.PartyAddress, motor.HPKW, motor.Remarks, motor.WarrantyStartDate,
+		motor.WarrantyEndDate, motor.PartyEmail, motor.PowerKW, motor.Version))
+	if err == sql.ErrNoRows {
+		return Motor{}, missingOrConflict(ctx, tx, motor.SerialNo)
+	}
+	if err == nil {
+		err = writeAudit(ctx, tx, "update", updated)
+	}
+	if err == nil {
+		err = commitTx(tx)
+	}
+	return updated, err
+}
+
+func (s *PostgresStore) patch(ctx context.Context, motor Motor, fields []string) (Motor, error) {
+	args := []interface{}{motor.SerialNo, motor.Version}
+	sets := make([]string, 0, len(fields)+2)
+	for _, key := range fields {
+		f := patchableFields[key]
+		args = append(args, f.value(motor))
+		sets = append(sets, fmt.Sprintf("%s = $%d", f.column, len(args)))
+		if key == "hp_kw" {
+			args = append(args, motor.PowerKW)
+			sets = append(sets, fmt.Sprintf("power_kw = $%d", len(args)))
+		}
+	}
+	sets = append(sets, "updated_at = now()", "version = version + 1")
+	query := "UPDATE motors SET " + strings.Join(sets, ", ") +
+		" WHERE " + liveSerialMatch + " AND version = $2 RETURNING " + motorSelectColumns
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Motor{}, err
+	}
+	defer tx.Rollback()
+
+	updated, err := scanMotor(tx.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return Motor{}, missingOrConflict(ctx, tx, motor.SerialNo)
+	}
+	if err == nil {
+		err = writeAudit(ctx, tx, "update", updated)
+	}
+	if err == nil {
+		err = commitTx(tx)
+	}
+	return updated, err
+}
+
+// missingOrConflict explains why a versioned update matched no rows: either
+// the motor is gone or someone else updated it first
+func missingOrConflict(ctx context.Context, tx *sql.Tx, serial string) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM motors WHERE "+liveSerialMatch+")", serial).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return ErrNotFound
+}
+
+func (s *PostgresStore) delete(ctx context.Context, serial string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Rows are only marked deleted so warranty history survives
+	deleted, err := scanMotor(tx.QueryRowContext(ctx,
+		"UPDATE motors SET deleted_at = now() WHERE "+liveSerialMatch+" RETURNING "+motorSelectColumns, serial))
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err == nil {
+		err = writeAudit(ctx, tx, "delete", deleted)
+	}
+	if err == nil {
+		err = commitTx(tx)
+	}
+	return err
+}
+
+func (s *PostgresStore) LookupResponse(ctx context.Context, actor, key string) (resp IdempotentResponse, found bool, err error) {
+	err = withRetry(ctx, func() error {
+		err := s.db.QueryRowContext(ctx,
+			`SELECT fingerprint, status, body FROM idempotency_keys 
+             WHERE actor = $1 AND key = $2 AND created_at > now() - $3 * interval '1 second'`,
+			actor, key, idempotencyTTL.Seconds()).Scan(&resp.Fingerprint, &resp.Status, &resp.Body)
+		found = err == nil
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	})
+	return resp, found, err
+}
+
+func (s *PostgresStore) SaveResponse(ctx context.Context, actor, key string, resp IdempotentResponse) error {
+	return withRetry(ctx, func() error {
+		// Expired keys are pruned here rather than by a separate job
+		_, err := s.db.ExecContext(ctx,
+			"DELETE FROM idempotency_keys WHERE created_at <= now() - $1 * interval '1 second'", idempotencyTTL.Seconds())
+		if err != nil {
+			return err
+		}
+		// A concurrent request with the same key may have saved first; its
+		// response stands
+		_, err = s.db.ExecContext(ctx,
+			`INSERT INTO idempotency_keys (actor, key, fingerprint, status, body) VALUES ($1, $2, $3, $4, $5) 
+             ON CONFLICT (actor, key) DO NOTHING`, actor, key, resp.Fingerprint, resp.Status, resp.Body)
+		return err
+	})
+}
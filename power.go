@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// kW per mechanical horsepower
+const kwPerHP = 0.7457
+
+// One rating within hp_kw, such as "5 HP", "3.7kW" or a bare "5"
+var powerPattern = regexp.MustCompile(`(?i)^\s*([0-9]+(?:\.[0-9]+)?)\s*(hp|kw)?\s*$`)
+
+var errBadPower = errors.New(`hp_kw must be a rating such as "5 HP", "3.7 kW" or "5 HP / 3.7 kW"`)
+
+// parsePowerKW reads an hp_kw value as kilowatts. Ratings given in both
+// units, separated by a slash, use the kW figure. A bare number is taken as
+// HP, since that's how ratings were entered before units were recorded.
+func parsePowerKW(s string) (float64, error) {
+	var hp, kw float64
+	var haveHP, haveKW bool
+	for _, part := range strings.Split(s, "/") {
+		m := powerPattern.FindStringSubmatch(part)
+		if m == nil {
+			return 0, errBadPower
+		}
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			return 0, errBadPower
+		}
+		if strings.EqualFold(m[2], "kw") {
+			kw, haveKW = v, true
+		} else {
+			hp, haveHP = v, true
+		}
+	}
+	if haveKW {
+		return kw, nil
+	}
+	if !haveHP {
+		return 0, errBadPower
+	}
+	return math.Round(hp*kwPerHP*1000) / 1000, nil
+}
+
+// motorPowerKW is m's rating in kW, or nil when hp_kw is empty or unparseable
+func motorPowerKW(m Motor) *float64 {
+	if strings.TrimSpace(m.HPKW) == "" {
+		return nil
+	}
+	kw, err := parsePowerKW(m.HPKW)
+	if err != nil {
+		return nil
+	}
+	return &kw
+}
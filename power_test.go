@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestParsePowerKW(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    float64
+		wantErr bool
+	}{
+		{in: "3.7 kW", want: 3.7},
+		{in: "3.7KW", want: 3.7},
+		{in: "5 HP", want: 3.729},
+		{in: "5hp", want: 3.729},
+		{in: "5", want: 3.729},
+		{in: "5 HP / 3.7 kW", want: 3.7},
+		{in: "five", wantErr: true},
+		{in: "5 HP /", wantErr: true},
+		{in: "3.7 kVA", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePowerKW(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parsePowerKW(%q): err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parsePowerKW(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
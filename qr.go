@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Edge length in pixels of generated QR labels
+const qrSize = 256
+
+// qrLink is the URL a motor's QR label points at. Labels are printed and
+// stuck on motors for years, so QR_LINK_BASE can name a stable page, such as
+// a frontend route, that the serial is appended to. Otherwise it's the
+// versioned API lookup under PUBLIC_BASE_URL, or the host the request was
+// made to.
+func qrLink(r *http.Request, serial string) string {
+	if base := strings.TrimRight(os.Getenv("QR_LINK_BASE"), "/"); base != "" {
+		return base + "/" + url.PathEscape(serial)
+	}
+	base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if base == "" {
+		base = "http://" + r.Host
+	}
+	return base + "/v1/motor/" + url.PathEscape(serial)
+}
+
+// motorQR returns a PNG QR code linking to the motor; see qrLink
+func (s *server) motorQR(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	// Don't hand out labels for motors that don't exist
+	motor, err := s.lookupMotor(r.Context(), serial)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	png, err := qrcode.Encode(qrLink(r, motor.SerialNo), qrcode.Medium, qrSize)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Error generating QR code")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(png)))
+	w.Write(png)
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestQRLink(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/motor/SN%201/qr", nil)
+	r.Host = "warranty.local"
+
+	tests := []struct {
+		linkBase, publicBase, want string
+	}{
+		{"", "", "http://warranty.local/v1/motor/SN%201"},
+		{"", "https://api.example.com/", "https://api.example.com/v1/motor/SN%201"},
+		{"https://example.com/m/", "https://api.example.com", "https://example.com/m/SN%201"},
+	}
+	for _, tt := range tests {
+		t.Setenv("QR_LINK_BASE", tt.linkBase)
+		t.Setenv("PUBLIC_BASE_URL", tt.publicBase)
+		if got := qrLink(r, "SN 1"); got != tt.want {
+			t.Errorf("QR_LINK_BASE=%q PUBLIC_BASE_URL=%q: link = %q, want %q", tt.linkBase, tt.publicBase, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Kinds of field a filter condition can test, which decide its operators
+// and how its value is read
+const (
+	fieldText   = "text"
+	fieldDate   = "date"
+	fieldInt    = "int"
+	fieldNumber = "number"
+)
+
+// Fields /fetch/query may filter on, by JSON name, with their column and kind
+var queryFields = map[string]struct{ column, kind string }{
+	"serial_no":           {"serial_no", fieldText},
+	"motor_model":         {"motor_model", fieldText},
+	"phase":               {"phase", fieldText},
+	"party_name":          {"party_name", fieldText},
+	"party_address":       {"party_address", fieldText},
+	"party_email":         {"party_email", fieldText},
+	"transport_agency":    {"transport_agency", fieldText},
+	"lr_eway_bill":        {"lr_or_eway_bill", fieldText},
+	"hp_kw":               {"hp_kw", fieldText},
+	"dispatch_date":       {"dispatch_date", fieldDate},
+	"warranty_start_date": {"warranty_start_date", fieldDate},
+	"warranty_end_date":   {"warranty_end_date", fieldDate},
+	"rpm":                 {"rpm", fieldInt},
+	"power_kw":            {"power_kw", fieldNumber},
+}
+
+// SQL for each comparison operator
+var queryComparisons = map[string]string{
+	"eq": "=", "ne": "<>", "lt": "<", "lte": "<=", "gt": ">", "gte": ">=",
+}
+
+// Operators allowed on each kind of field
+var queryKindOps = map[string][]string{
+	fieldText:   {"eq", "ne", "in", "contains"},
+	fieldDate:   {"eq", "ne", "lt", "lte", "gt", "gte", "in"},
+	fieldInt:    {"eq", "ne", "lt", "lte", "gt", "gte", "in"},
+	fieldNumber: {"eq", "ne", "lt", "lte", "gt", "gte", "in"},
+}
+
+// Limits on the size of a filter, so one request can't build a huge query
+const (
+	maxQueryDepth      = 5
+	maxQueryConditions = 50
+	maxQueryInValues   = 100
+)
+
+// filterNode is one node of a /fetch/query filter: either a group of nodes
+// joined by "and" or "or", or a single condition on a field
+type filterNode struct {
+	And   []filterNode    `json:"and"`
+	Or    []filterNode    `json:"or"`
+	Field string          `json:"field"`
+	Op    string          `json:"op"`
+	Value json.RawMessage `json:"value"`
+}
+
+// filterCompiler turns filter nodes into SQL, collecting the bound args
+type filterCompiler struct {
+	args       []interface{}
+	conditions int
+}
+
+func (c *filterCompiler) bind(v interface{}) string {
+	c.args = append(c.args, v)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// compile returns n as a SQL condition. Only allowlisted columns and
+// operators reach the SQL text; every value is bound.
+func (c *filterCompiler) compile(n filterNode, depth int) (string, error) {
+	if depth > maxQueryDepth {
+		return "", fmt.Errorf("filters may nest at most %d deep", maxQueryDepth)
+	}
+	kinds := 0
+	for _, set := range []bool{n.And != nil, n.Or != nil, n.Field != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return "", errors.New(`each filter must have exactly one of "and", "or" or "field"`)
+	}
+
+	if n.Field == "" {
+		children, joiner := n.And, " AND "
+		if n.Or != nil {
+			children, joiner = n.Or, " OR "
+		}
+		if len(children) == 0 {
+			return "", errors.New(`"and" and "or" need at least one filter`)
+		}
+		parts := make([]string, len(children))
+		for i, child := range children {
+			sql, err := c.compile(child, depth+1)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = sql
+		}
+		return "(" + strings.Join(parts, joiner) + ")", nil
+	}
+
+	if c.conditions++; c.conditions > maxQueryConditions {
+		return "", fmt.Errorf("at most %d conditions are allowed", maxQueryConditions)
+	}
+	field, ok := queryFields[n.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", n.Field)
+	}
+	allowed := false
+	for _, op := range queryKindOps[field.kind] {
+		allowed = allowed || op == n.Op
+	}
+	if !allowed {
+		return "", fmt.Errorf("operator %q can't be used on %s", n.Op, n.Field)
+	}
+
+	switch n.Op {
+	case "in":
+		var raw []json.RawMessage
+		if err := json.Unmarshal(n.Value, &raw); err != nil || len(raw) == 0 || len(raw) > maxQueryInValues {
+			return "", fmt.Errorf("in on %s needs a list of 1 to %d values", n.Field, maxQueryInValues)
+		}
+		placeholders := make([]string, len(raw))
+		for i, r := range raw {
+			v, err := queryValue(n.Field, field.kind, r)
+			if err != nil {
+				return "", err
+			}
+			placeholders[i] = c.bind(v)
+		}
+		return fmt.Sprintf("%s IN (%s)", field.column, strings.Join(placeholders, ", ")), nil
+	case "contains":
+		v, err := queryValue(n.Field, field.kind, n.Value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf(`%s ILIKE '%%' || %s || '%%'`, field.column, c.bind(escapeLike(v.(string)))), nil
+	}
+	v, err := queryValue(n.Field, field.kind, n.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", field.column, queryComparisons[n.Op], c.bind(v)), nil
+}
+
+// queryValue reads a condition's value as the field's kind requires
+func queryValue(name, kind string, raw json.RawMessage) (interface{}, error) {
+	switch kind {
+	case fieldInt:
+		var v int
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be compared with a whole number", name)
+		}
+		return v, nil
+	case fieldNumber:
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, fmt.Errorf("%s must be compared with a number", name)
+		}
+		return v, nil
+	}
+	var v string
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("%s must be compared with a string", name)
+	}
+	if kind == fieldDate {
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return nil, fmt.Errorf("%s must be compared with a date (YYYY-MM-DD)", name)
+		}
+	}
+	return v, nil
+}
+
+// queryMotors lists motors matching a filter given as JSON, such as
+//
+//	{"or": [
+//	  {"and": [{"field": "phase", "op": "eq", "value": "three"},
+//	           {"field": "rpm", "op": "gt", "value": 1400}]},
+//	  {"field": "motor_model", "op": "eq", "value": "XYZ"}
+//	]}
+//
+// Paging, sorting and include_deleted work as they do for /fetch.
+func queryMotors(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	var filter filterNode
+	if err := decoder.Decode(&filter); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid filter")
+		return
+	}
+
+	var c filterCompiler
+	cond, err := c.compile(filter, 1)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	include, err := includeDeleted(r)
+	if err != nil {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if !include {
+		cond = "deleted_at IS NULL AND " + cond
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	order, err := parseSort(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var total int
+	err = db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM motors WHERE "+cond, c.args...).Scan(&total)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	if total == 0 {
+		writeJSONError(w, http.StatusNotFound, "No motors found")
+		return
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM motors WHERE %s %s LIMIT $%d OFFSET $%d",
+		motorSelectColumns, cond, order.clause(), len(c.args)+1, len(c.args)+2)
+	rows, err := db.QueryContext(r.Context(), query, append(c.args, limit, offset)...)
+	if err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	motors := []map[string]interface{}{}
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		motors = append(motors, motorResponse(motor))
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error fetching motors: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":      motors,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+		"truncated": markTruncated(w, offset+len(motors) < total),
+	})
+}
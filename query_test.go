@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   string
+		wantSQL  string
+		wantArgs []interface{}
+		wantErr  bool
+	}{
+		{
+			name: "and within or",
+			filter: `{"or": [
+				{"and": [{"field": "phase", "op": "eq", "value": "three"}, {"field": "rpm", "op": "gt", "value": 1400}]},
+				{"field": "motor_model", "op": "eq", "value": "XYZ"}]}`,
+			wantSQL:  "((phase = $1 AND rpm > $2) OR motor_model = $3)",
+			wantArgs: []interface{}{"three", 1400, "XYZ"},
+		},
+		{
+			name:     "contains escapes wildcards",
+			filter:   `{"field": "party_address", "op": "contains", "value": "50%"}`,
+			wantSQL:  `party_address ILIKE '%' || $1 || '%'`,
+			wantArgs: []interface{}{`50\%`},
+		},
+		{
+			name:     "list",
+			filter:   `{"field": "lr_eway_bill", "op": "in", "value": ["LR1", "LR2"]}`,
+			wantSQL:  "lr_or_eway_bill IN ($1, $2)",
+			wantArgs: []interface{}{"LR1", "LR2"},
+		},
+		{name: "unknown field", filter: `{"field": "id; DROP TABLE motors", "op": "eq", "value": 1}`, wantErr: true},
+		{name: "unknown operator", filter: `{"field": "rpm", "op": "like", "value": 1}`, wantErr: true},
+		{name: "range on text", filter: `{"field": "party_name", "op": "gt", "value": "A"}`, wantErr: true},
+		{name: "wrong value type", filter: `{"field": "rpm", "op": "gt", "value": "fast"}`, wantErr: true},
+		{name: "bad date", filter: `{"field": "dispatch_date", "op": "gte", "value": "01/02/2024"}`, wantErr: true},
+		{name: "empty group", filter: `{"and": []}`, wantErr: true},
+		{name: "field and group", filter: `{"field": "rpm", "op": "eq", "value": 1, "or": [{"field": "rpm", "op": "eq", "value": 2}]}`, wantErr: true},
+		{name: "too deep", filter: `{"and": [{"and": [{"and": [{"and": [{"and": [{"field": "rpm", "op": "eq", "value": 1}]}]}]}]}]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n filterNode
+			if err := json.Unmarshal([]byte(tt.filter), &n); err != nil {
+				t.Fatalf("decoding filter: %v", err)
+			}
+			var c filterCompiler
+			sql, err := c.compile(n, 1)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(c.args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", c.args, tt.wantArgs)
+			}
+		})
+	}
+}
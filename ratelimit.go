@@ -0,0 +1,88 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiters for clients idle longer than this are dropped
+const rateLimiterIdle = 3 * time.Minute
+
+type visitor struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// ipRateLimiter holds a token bucket per client IP
+type ipRateLimiter struct {
+	mu       sync.Mutex
+	visitors map[string]*visitor
+	rps      rate.Limit
+	burst    int
+}
+
+// newIPRateLimiter allows each client rps requests per second on average,
+// with bursts of up to burst requests
+func newIPRateLimiter(rps float64, burst int) *ipRateLimiter {
+	l := &ipRateLimiter{
+		visitors: make(map[string]*visitor),
+		rps:      rate.Limit(rps),
+		burst:    burst,
+	}
+	go l.cleanup()
+	return l
+}
+
+func (l *ipRateLimiter) get(ip string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	v, ok := l.visitors[ip]
+	if !ok {
+		v = &visitor{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.visitors[ip] = v
+	}
+	v.lastSeen = time.Now()
+	return v.limiter
+}
+
+// cleanup periodically forgets clients that have gone quiet
+func (l *ipRateLimiter) cleanup() {
+	for range time.Tick(time.Minute) {
+		l.mu.Lock()
+		for ip, v := range l.visitors {
+			if time.Since(v.lastSeen) > rateLimiterIdle {
+				delete(l.visitors, ip)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// middleware rejects requests over the client's limit with 429 and a
+// Retry-After header saying when the next one would be allowed
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		limiter := l.get(ip)
+		if !limiter.Allow() {
+			res := limiter.Reserve()
+			wait := res.Delay()
+			res.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Max(1, math.Ceil(wait.Seconds())))))
+			writeJSONError(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
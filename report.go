@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Columns /report may group by, keyed by their group_by value
+var reportGroupColumns = map[string]string{
+	"party":            "party_name",
+	"model":            "motor_model",
+	"phase":            "phase",
+	"transport_agency": "transport_agency",
+}
+
+// Periods /report may bucket dispatch dates by, as date_trunc fields
+var reportPeriods = map[string]bool{"week": true, "month": true, "quarter": true, "year": true}
+
+// buildReportQuery returns the query counting live motors per dispatch
+// period and group, with their warranty breakdown as of today. groupBy and
+// period must already be allowlisted.
+func buildReportQuery(groupBy, period, from, to, today string) (string, []interface{}) {
+	// Only real dates can be bucketed, so malformed or impossible ones are
+	// left out rather than failing the query
+	filters := map[string]interface{}{
+		"deleted_at IS NULL":                   nil,
+		"safe_date(dispatch_date) IS NOT NULL": nil,
+	}
+	if from != "" {
+		filters["dispatch_date >= $%d"] = from
+	}
+	if to != "" {
+		filters["dispatch_date <= $%d"] = to
+	}
+	where, args := buildWhere(filters)
+	args = append(args, today)
+	todayArg := len(args)
+
+	query := fmt.Sprintf(`SELECT to_char(date_trunc('%s', safe_date(dispatch_date)), 'YYYY-MM-DD'), COALESCE(%s, ''), COUNT(*),
+               COUNT(*) FILTER (WHERE warranty_end_date ~ '^\d{4}-\d{2}-\d{2}$' AND warranty_end_date >= $%d),
+               COUNT(*) FILTER (WHERE warranty_end_date ~ '^\d{4}-\d{2}-\d{2}$' AND warranty_end_date < $%d)
+        FROM motors %s GROUP BY 1, 2 ORDER BY 1, 2`,
+		period, reportGroupColumns[groupBy], todayArg, todayArg, where)
+	return query, args
+}
+
+// motorReport counts dispatched motors per period (month by default) and
+// per party, model, phase or transport agency, with how many of each
+// group's warranties are active or expired
+func motorReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	groupBy := strings.TrimSpace(q.Get("group_by"))
+	if groupBy == "" {
+		groupBy = "party"
+	}
+	if _, ok := reportGroupColumns[groupBy]; !ok {
+		writeJSONError(w, http.StatusBadRequest, "group_by must be party, model, phase or transport_agency")
+		return
+	}
+	period := strings.TrimSpace(q.Get("period"))
+	if period == "" {
+		period = "month"
+	}
+	if !reportPeriods[period] {
+		writeJSONError(w, http.StatusBadRequest, "period must be week, month, quarter or year")
+		return
+	}
+	from, to, err := parseDispatchRange(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, args := buildReportQuery(groupBy, period, from, to, time.Now().Format("2006-01-02"))
+	rows, err := db.QueryContext(r.Context(), query, args...)
+	if err != nil {
+		dbError(w, r, "Error computing report: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	report := []map[string]interface{}{}
+	for rows.Next() {
+		var start, group string
+		var count, active, expired int
+		if err := rows.Scan(&start, &group, &count, &active, &expired); err != nil {
+			dbError(w, r, "Error computing report: "+err.Error())
+			return
+		}
+		report = append(report, map[string]interface{}{
+			"period": start,
+			"group":  group,
+			"count":  count,
+			"warranty": map[string]int{
+				"active":  active,
+				"expired": expired,
+				"unknown": count - active - expired,
+			},
+		})
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error computing report: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"group_by": groupBy,
+		"period":   period,
+		"data":     report,
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildReportQuery(t *testing.T) {
+	query, args := buildReportQuery("model", "quarter", "2024-01-01", "", "2025-01-15")
+
+	for _, want := range []string{
+		"date_trunc('quarter', safe_date(dispatch_date))",
+		"safe_date(dispatch_date) IS NOT NULL",
+		"COALESCE(motor_model, '')",
+		"warranty_end_date >= $2",
+		"AND dispatch_date >= $1",
+		"GROUP BY 1, 2",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query doesn't contain %q:\n%s", want, query)
+		}
+	}
+	if want := []interface{}{"2024-01-01", "2025-01-15"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+// Bad params are refused before the database is touched; db is nil here
+func TestMotorReportParams(t *testing.T) {
+	for _, query := range []string{"group_by=serial_no", "period=day", "group_by=party_name", "dispatch_from=Jan"} {
+		rec := httptest.NewRecorder()
+		motorReport(rec, httptest.NewRequest("GET", "/report?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// How often withRetry tries a call, and the wait before the first retry,
+// which doubles each time after
+const (
+	dbRetryAttempts = 3
+	dbRetryBackoff  = 100 * time.Millisecond
+)
+
+// withRetry calls fn until it succeeds, fails with an error that retrying
+// won't fix, or has been tried dbRetryAttempts times. fn must be safe to
+// repeat, such as a whole transaction committed with commitTx.
+func withRetry(ctx context.Context, fn func() error) error {
+	wait := dbRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt == dbRetryAttempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// errCommitUnknown marks a commit whose connection failed before Postgres
+// answered. The transaction may or may not have been stored, so repeating
+// it could report a conflict for a write that succeeded.
+var errCommitUnknown = errors.New("commit outcome unknown")
+
+// commitTx commits tx for a transaction run under withRetry. An error
+// returned by Postgres means the transaction was rolled back and keeps its
+// usual retry rules; any other failure wraps errCommitUnknown so it's final.
+func commitTx(tx *sql.Tx) error {
+	err := tx.Commit()
+	var pqErr *pq.Error
+	if err == nil || errors.As(err, &pqErr) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", errCommitUnknown, err)
+}
+
+// Longest wait between pings while waiting for the database at startup
+const maxStartupBackoff = 5 * time.Second
+
+// waitForDB pings the database until it answers or ctx is done, backing off
+// between attempts. Every error is retried, since at startup the server is
+// usually just still booting.
+func waitForDB(ctx context.Context, ping func(context.Context) error) error {
+	wait := dbRetryBackoff
+	for {
+		err := ping(ctx)
+		if err == nil {
+			return nil
+		}
+		slog.Warn("Database not ready yet", "error", err, "retry_in", wait.String())
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxStartupBackoff)
+	}
+}
+
+// Postgres error codes worth retrying: the connection was lost or refused,
+// the server is restarting, or the transaction lost a serialization race.
+// Constraint violations and the like are never retried.
+var transientPQCodes = map[pq.ErrorCode]bool{
+	"40001": true, // serialization_failure
+	"40P01": true, // deadlock_detected
+	"57P01": true, // admin_shutdown
+	"57P02": true, // crash_shutdown
+	"57P03": true, // cannot_connect_now
+}
+
+// isTransient reports whether err looks like a brief database outage rather
+// than a problem with the request
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, errCommitUnknown) {
+		return false
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection_exception
+		return pqErr.Code.Class() == "08" || transientPQCodes[pqErr.Code]
+	}
+
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.As(err, &netErr)
+}
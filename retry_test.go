@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCalls int
+	}{
+		{"success", nil, 1},
+		{"bad connection", driver.ErrBadConn, dbRetryAttempts},
+		{"connection failure", &pq.Error{Code: "08006"}, dbRetryAttempts},
+		{"serialization failure", fmt.Errorf("update: %w", &pq.Error{Code: "40001"}), dbRetryAttempts},
+		{"unique violation", &pq.Error{Code: "23505"}, 1},
+		{"not found", ErrNotFound, 1},
+		{"cancelled", context.Canceled, 1},
+		{"commit connection lost", fmt.Errorf("%w: %w", errCommitUnknown, driver.ErrBadConn), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := withRetry(context.Background(), func() error {
+				calls++
+				return tt.err
+			})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("called %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWithRetryRecovers(t *testing.T) {
+	calls := 0
+	err := withRetry(context.Background(), func() error {
+		if calls++; calls < 2 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("err = %v after %d calls, want success on the second", err, calls)
+	}
+}
+
+func TestWaitForDB(t *testing.T) {
+	calls := 0
+	err := waitForDB(context.Background(), func(context.Context) error {
+		if calls++; calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("err = %v after %d pings, want success on the third", err, calls)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	down := errors.New("connection refused")
+	if err := waitForDB(ctx, func(context.Context) error { return down }); err != down {
+		t.Errorf("err = %v, want the last ping error once the timeout passes", err)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Motor fields the server fills in, which clients can't set
+var motorReadOnlyFields = map[string]bool{
+	"id": true, "power_kw": true, "created_at": true, "updated_at": true,
+}
+
+// Constraints on motor fields beyond their Go type, matching motorProblems
+var motorFieldConstraints = map[string]map[string]interface{}{
+	"rpm":                 {"minimum": 1},
+	"phase":               {"enum": []string{"single", "three"}},
+	"dispatch_date":       {"format": "date"},
+	"warranty_start_date": {"format": "date"},
+	"warranty_end_date":   {"format": "date"},
+	"party_email":         {"format": "email"},
+}
+
+// validMotorSample passes validateMotor, so clearing one field at a time
+// shows which fields it requires
+var validMotorSample = Motor{
+	SerialNo: "SN1", MotorModel: "M1", RPM: 1440, Phase: "three", DispatchDate: "2024-01-01",
+}
+
+// requiredMotorFields lists, by JSON name, the Motor fields validateMotor
+// rejects a motor for leaving empty. It's worked out by clearing each field of
+// validMotorSample in turn, so it follows validateMotor as that changes.
+func requiredMotorFields() []string {
+	t := reflect.TypeOf(Motor{})
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		m := validMotorSample
+		v := reflect.ValueOf(&m).Elem().Field(i)
+		v.Set(reflect.Zero(v.Type()))
+		if validateMotor(m) != nil {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+	return required
+}
+
+// motorSchema describes Motor's JSON encoding, with the fields
+// validateMotor requires and what's known about each field's values
+func motorSchema() map[string]interface{} {
+	schema := structSchema(reflect.TypeOf(Motor{}))
+	props := schema["properties"].(map[string]interface{})
+	for name, prop := range props {
+		p := prop.(map[string]interface{})
+		for k, v := range motorFieldConstraints[name] {
+			p[k] = v
+		}
+		if motorReadOnlyFields[name] {
+			p["readOnly"] = true
+		}
+	}
+	schema["required"] = requiredMotorFields()
+	return schema
+}
+
+// motorJSONSchema serves motorSchema as a standalone JSON Schema document,
+// for clients that build forms from it
+func motorJSONSchema(w http.ResponseWriter, r *http.Request) {
+	schema := motorSchema()
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Motor"
+	w.Header().Set("Content-Type", "application/schema+json")
+	json.NewEncoder(w).Encode(schema)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMotorJSONSchema(t *testing.T) {
+	rec := httptest.NewRecorder()
+	motorJSONSchema(rec, httptest.NewRequest("GET", "/schema/motor", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var schema struct {
+		Required   []string                          `json:"required"`
+		Properties map[string]map[string]interface{} `json:"properties"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&schema); err != nil {
+		t.Fatalf("decoding schema: %v", err)
+	}
+
+	want := []string{"dispatch_date", "motor_model", "phase", "rpm", "serial_no"}
+	if !reflect.DeepEqual(schema.Required, want) {
+		t.Errorf("required = %v, want %v", schema.Required, want)
+	}
+	if got := schema.Properties["rpm"]["type"]; got != "integer" {
+		t.Errorf("rpm type = %v, want integer", got)
+	}
+	if got := schema.Properties["id"]["readOnly"]; got != true {
+		t.Errorf("id readOnly = %v, want true", got)
+	}
+	if len(schema.Properties) != reflect.TypeOf(Motor{}).NumField() {
+		t.Errorf("%d properties, want one per Motor field", len(schema.Properties))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// searchDocument is the text /search matches against
+const searchDocument = `coalesce(serial_no, '') || ' ' || coalesce(motor_model, '') || ' ' || 
+    coalesce(party_name, '') || ' ' || coalesce(party_address, '') || ' ' || coalesce(transport_agency, '')`
+
+// searchMotors finds live motors mentioning q in any of the searchable
+// fields, either as whole words or as a substring so fragments of serials and
+// addresses still turn up. Serial number matches come first, then the rest
+// in order of full-text rank.
+func searchMotors(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		writeJSONError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// $1 is the word query, $2 the escaped substring
+	query := "SELECT " + motorSelectColumns + ` FROM motors 
+        WHERE deleted_at IS NULL 
+          AND (to_tsvector('simple', ` + searchDocument + `) @@ plainto_tsquery('simple', $1) 
+               OR (` + searchDocument + `) ILIKE '%' || $2 || '%') 
+        ORDER BY (serial_no ILIKE '%' || $2 || '%') DESC, 
+                 ts_rank(to_tsvector('simple', ` + searchDocument + `), plainto_tsquery('simple', $1)) DESC, id 
+        LIMIT $3 OFFSET $4`
+
+	// One extra row says whether more matched than are returned
+	rows, err := db.QueryContext(r.Context(), query, q, escapeLike(q), limit+1, offset)
+	if err != nil {
+		dbError(w, r, "Error searching motors: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	motors := []map[string]interface{}{}
+	for rows.Next() {
+		motor, err := scanMotor(rows)
+		if err != nil {
+			dbError(w, r, "Error scanning row: "+err.Error())
+			return
+		}
+		motors = append(motors, motorResponse(motor))
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error searching motors: "+err.Error())
+		return
+	}
+
+	truncated := len(motors) > limit
+	if truncated {
+		motors = motors[:limit]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data":      motors,
+		"limit":     limit,
+		"offset":    offset,
+		"truncated": markTruncated(w, truncated),
+	})
+}
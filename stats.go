@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Dispatches this recent count towards dispatched_last_30_days
+const recentDispatchDays = 30
+
+// motorStats serves the dashboard summary of live motors. Everything is
+// aggregated in SQL so no rows are pulled into Go.
+func motorStats(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	today := now.Format("2006-01-02")
+	since := now.AddDate(0, 0, -recentDispatchDays).Format("2006-01-02")
+
+	// Dates are YYYY-MM-DD strings, so they compare correctly as text.
+	// Anything else can't be classified and counts as unknown, as in
+	// computeWarrantyStatus.
+	var total, active, expired, recent int
+	err := db.QueryRowContext(r.Context(), `
+        SELECT COUNT(*),
+               COUNT(*) FILTER (WHERE warranty_end_date ~ '^\d{4}-\d{2}-\d{2}$' AND warranty_end_date >= $1),
+               COUNT(*) FILTER (WHERE warranty_end_date ~ '^\d{4}-\d{2}-\d{2}$' AND warranty_end_date < $1),
+               COUNT(*) FILTER (WHERE dispatch_date >= $2 AND dispatch_date <= $1)
+        FROM motors WHERE deleted_at IS NULL`, today, since).Scan(&total, &active, &expired, &recent)
+	if err != nil {
+		dbError(w, r, "Error computing stats: "+err.Error())
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT phase, COUNT(*) FROM motors WHERE deleted_at IS NULL GROUP BY phase")
+	if err != nil {
+		dbError(w, r, "Error computing stats: "+err.Error())
+		return
+	}
+	defer rows.Close()
+
+	byPhase := map[string]int{}
+	for rows.Next() {
+		var phase string
+		var count int
+		if err := rows.Scan(&phase, &count); err != nil {
+			dbError(w, r, "Error computing stats: "+err.Error())
+			return
+		}
+		byPhase[phase] = count
+	}
+	if err := rows.Err(); err != nil {
+		dbError(w, r, "Error computing stats: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"total":    total,
+		"by_phase": byPhase,
+		"warranty": map[string]int{
+			"active":  active,
+			"expired": expired,
+			"unknown": total - active - expired,
+		},
+		"dispatched_last_30_days": recent,
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	// ErrNotFound means no live motor has the requested serial number
+	ErrNotFound = errors.New("motor not found")
+	// ErrDuplicateSerial means a motor with that serial number already exists
+	ErrDuplicateSerial = errors.New("serial number already exists")
+	// ErrVersionConflict means the motor was updated since the version the
+	// caller read
+	ErrVersionConflict = errors.New("motor version has changed")
+)
+
+// BatchError says which motor stopped a RegisterBatch call, and why
+type BatchError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchError) Error() string { return fmt.Sprintf("motor %d: %v", e.Index, e.Err) }
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// MotorQuery selects one page of motors
+type MotorQuery struct {
+	Filter MotorFilter
+	Sort   sortOrder
+	Limit  int
+	Offset int
+	// Cursor pages by ascending id in place of Sort and Offset, returning
+	// motors with an id above After. The filter may then be empty, and the
+	// total isn't counted.
+	Cursor bool
+	After  int64
+	// Fields names the response fields the caller wants, as parseFields
+	// returns them. A store may leave the rest of each motor unset; nil
+	// loads every field.
+	Fields []string
+}
+
+// MotorStore is the data layer behind the motor handlers. Motors are keyed
+// by serial number, and soft-deleted motors are invisible to everything but
+// Fetch with Filter.IncludeDeleted set.
+type MotorStore interface {
+	// Register stores a new motor and returns it as stored
+	Register(ctx context.Context, motor Motor) (Motor, error)
+	// RegisterBatch stores every motor or none of them, returning them as
+	// stored. When a motor fails the error is a *BatchError with its index.
+	RegisterBatch(ctx context.Context, motors []Motor) ([]Motor, error)
+	// Fetch returns the requested page of matching motors along with the
+	// total number of matches. Outside cursor mode the filter must set at
+	// least one field.
+	Fetch(ctx context.Context, q MotorQuery) ([]Motor, int, error)
+	GetBySerial(ctx context.Context, serial string) (Motor, error)
+	// GetBySerials returns the live motors among serials, in no particular
+	// order. Serials that don't match are simply left out.
+	GetBySerials(ctx context.Context, serials []string) ([]Motor, error)
+	// FindSimilar returns a live motor whose serial number equals serial
+	// once both are trimmed and uppercased
+	FindSimilar(ctx context.Context, serial string) (Motor, error)
+	// Update overwrites the stored motor with motor.SerialNo, provided it's
+	// still at motor.Version, and returns the result
+	Update(ctx context.Context, motor Motor) (Motor, error)
+	// Patch is Update limited to the named patchableFields of motor
+	Patch(ctx context.Context, motor Motor, fields []string) (Motor, error)
+	// Delete soft-deletes the motor with the given serial number
+	Delete(ctx context.Context, serial string) error
+}
+
+// server holds what the store-backed handlers depend on
+type server struct {
+	store MotorStore
+	// mail sends registration confirmations; nil when SMTP isn't configured
+	mail *mailer
+	// hooks notifies webhook receivers; nil when none are configured
+	hooks *webhookDispatcher
+	// docs holds uploaded documents such as certificate scans
+	docs DocStore
+	// keys saves responses for Idempotency-Key retries; nil turns that off
+	keys IdempotencyStore
+	// cache holds /stats and /parties responses; nil turns caching off
+	cache *responseCache
+	// maintenance turns away writes while it's on; nil leaves writes open
+	// and the toggle unregistered
+	maintenance *maintenanceMode
+	// lookups collapses concurrent reads of the same motor; see lookupMotor
+	lookups singleflight.Group
+	// requestTimeout is REQUEST_TIMEOUT, given to work that outlives the
+	// request that started it; zero means no deadline
+	requestTimeout time.Duration
+}
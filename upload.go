@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+
+	"github.com/google/uuid"
+)
+
+// Upload size cap when CERT_MAX_BYTES isn't set
+const defaultCertMaxBytes = 10 << 20
+
+// File extensions for the certificate formats we accept, keyed by sniffed
+// content type
+var certificateTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// uploadCertificate stores a scan of a motor's test certificate from the
+// "file" field of a multipart form, replacing any earlier upload
+func (s *server) uploadCertificate(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+	r.Body = http.MaxBytesReader(w, r.Body, int64(envInt("CERT_MAX_BYTES", defaultCertMaxBytes)))
+
+	file, header, err := r.FormFile("file")
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "File too large")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "A multipart file field named file is required")
+		return
+	}
+	defer file.Close()
+
+	// Go by the file's contents rather than the type the client declared
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		writeJSONError(w, http.StatusBadRequest, "Could not read file")
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	ext, ok := certificateTypes[contentType]
+	if !ok {
+		writeJSONError(w, http.StatusUnsupportedMediaType, "Certificate must be a PDF, JPEG or PNG")
+		return
+	}
+
+	var previous string
+	err = db.QueryRowContext(r.Context(),
+		"SELECT certificate_path FROM motors WHERE "+liveSerialMatch, serial).Scan(&previous)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+
+	// Serials aren't safe as keys, so each upload gets a fresh one
+	key := "certificates/" + uuid.NewString() + ext
+	err = s.docs.Put(r.Context(), key, io.MultiReader(bytes.NewReader(head), file), header.Size, contentType)
+	if err != nil {
+		slog.Error("Saving certificate failed", "request_id", requestIDFrom(r.Context()), "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error saving certificate")
+		return
+	}
+
+	err = saveCertificatePath(r.Context(), serial, key)
+	// If the commit may have gone through, the new key could be in use
+	if err != nil && !errors.Is(err, errCommitUnknown) {
+		s.docs.Delete(r.Context(), key)
+	}
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error saving certificate: "+err.Error())
+		return
+	}
+	if previous != "" {
+		if err := s.docs.Delete(r.Context(), previous); err != nil {
+			slog.Warn("Removing old certificate failed", "request_id", requestIDFrom(r.Context()), "key", previous, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Certificate uploaded", "key": key})
+}
+
+// saveCertificatePath points the live motor with serial at the certificate
+// stored under key, auditing the change in the same transaction
+func saveCertificatePath(ctx context.Context, serial, key string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	updated, err := scanMotor(tx.QueryRowContext(ctx,
+		"UPDATE motors SET certificate_path = $2, updated_at = now(), version = version + 1 WHERE "+liveSerialMatch+
+			" RETURNING "+motorSelectColumns,
+		serial, key))
+	if err == nil {
+		err = writeAudit(ctx, tx, "certificate", updated)
+	}
+	if err == nil {
+		err = commitTx(tx)
+	}
+	return err
+}
+
+// certificateDisposition is the Content-Disposition for downloading the
+// certificate of the motor with serial. Legacy serials may hold spaces or
+// quotes, so the filename is quoted, or encoded when it isn't plain ASCII.
+func certificateDisposition(serial, ext string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": "certificate-" + serial + ext})
+}
+
+// downloadCertificate streams back a motor's uploaded certificate
+func (s *server) downloadCertificate(w http.ResponseWriter, r *http.Request) {
+	serial := serialParam(r)
+
+	var key string
+	err := db.QueryRowContext(r.Context(),
+		"SELECT certificate_path FROM motors WHERE "+liveSerialMatch, serial).Scan(&key)
+	if err == sql.ErrNoRows {
+		writeJSONError(w, http.StatusNotFound, "Motor not found")
+		return
+	}
+	if err != nil {
+		dbError(w, r, "Error fetching motor: "+err.Error())
+		return
+	}
+	if key == "" {
+		writeJSONError(w, http.StatusNotFound, "No certificate uploaded")
+		return
+	}
+
+	doc, err := s.docs.Get(r.Context(), key)
+	if err == ErrDocNotFound {
+		writeJSONError(w, http.StatusNotFound, "No certificate uploaded")
+		return
+	}
+	if err != nil {
+		slog.Error("Reading certificate failed", "request_id", requestIDFrom(r.Context()), "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "Error reading certificate")
+		return
+	}
+	defer doc.Close()
+
+	ext := path.Ext(key)
+	for contentType, known := range certificateTypes {
+		if ext == known {
+			w.Header().Set("Content-Type", contentType)
+		}
+	}
+	w.Header().Set("Content-Disposition", certificateDisposition(serial, ext))
+	io.Copy(w, doc)
+}
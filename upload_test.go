@@ -0,0 +1,20 @@
+package main
+
+import (
+	"mime"
+	"testing"
+)
+
+func TestCertificateDisposition(t *testing.T) {
+	for _, serial := range []string{"SN1", "SN 1", `SN"1;x=y`, "SNé1"} {
+		header := certificateDisposition(serial, ".pdf")
+		disposition, params, err := mime.ParseMediaType(header)
+		if err != nil {
+			t.Errorf("%q: parsing %q: %v", serial, header, err)
+			continue
+		}
+		if want := "certificate-" + serial + ".pdf"; disposition != "attachment" || params["filename"] != want {
+			t.Errorf("%q: %q parses as %s %q, want attachment %q", serial, header, disposition, params["filename"], want)
+		}
+	}
+}
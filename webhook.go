@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	webhookAttempts = 4
+	webhookTimeout  = 10 * time.Second
+)
+
+// webhookDispatcher POSTs event notifications to the URLs in WEBHOOK_URLS.
+// Each body is signed with WEBHOOK_SECRET in the X-Webhook-Signature header
+// as "sha256=" followed by the hex HMAC-SHA256 of the body.
+type webhookDispatcher struct {
+	urls   []string
+	secret []byte
+	client *http.Client
+	// backoff is the wait before the first retry; it doubles for each one
+	// after that
+	backoff time.Duration
+}
+
+// newWebhookDispatcherFromEnv returns nil, turning webhooks off, when
+// WEBHOOK_URLS is empty
+func newWebhookDispatcherFromEnv() *webhookDispatcher {
+	urls := envList("WEBHOOK_URLS", nil)
+	if len(urls) == 0 {
+		return nil
+	}
+	if os.Getenv("WEBHOOK_SECRET") == "" {
+		slog.Warn("WEBHOOK_SECRET is not set; webhook receivers can't verify deliveries")
+	}
+	return &webhookDispatcher{
+		urls:    urls,
+		secret:  []byte(os.Getenv("WEBHOOK_SECRET")),
+		client:  &http.Client{Timeout: webhookTimeout},
+		backoff: time.Second,
+	}
+}
+
+// sign returns the X-Webhook-Signature value for body
+func (d *webhookDispatcher) sign(body []byte) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// dispatch sends event with data to every configured URL in the
+// background, so callers never wait on receivers
+func (d *webhookDispatcher) dispatch(event string, data interface{}, requestID string) {
+	body, err := json.Marshal(map[string]interface{}{"event": event, "data": data})
+	if err != nil {
+		slog.Error("Encoding webhook failed", "request_id", requestID, "event", event, "error", err)
+		return
+	}
+	for _, url := range d.urls {
+		go func(url string) {
+			if err := d.deliver(url, event, body); err != nil {
+				slog.Error("Webhook delivery failed", "request_id", requestID, "event", event, "url", url, "error", err)
+			}
+		}(url)
+	}
+}
+
+// deliver POSTs body to url, retrying with exponential backoff until it
+// gets a 2xx or runs out of attempts
+func (d *webhookDispatcher) deliver(url, event string, body []byte) error {
+	signature := d.sign(body)
+	wait := d.backoff
+
+	var err error
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		var req *http.Request
+		req, err = http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Webhook-Event", event)
+		req.Header.Set("X-Webhook-Signature", signature)
+
+		var resp *http.Response
+		resp, err = d.client.Do(req)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", webhookAttempts, err)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWebhookDeliverRetriesAndSigns(t *testing.T) {
+	d := &webhookDispatcher{secret: []byte("s3cret"), client: http.DefaultClient}
+	body := []byte(`{"event":"motor.registered"}`)
+
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := io.ReadAll(r.Body)
+		if sig := r.Header.Get("X-Webhook-Signature"); sig != d.sign(got) {
+			t.Errorf("signature %q does not match body", sig)
+		}
+		// Fail the first two attempts
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+
+	if err := d.deliver(srv.URL, "motor.registered", body); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("receiver called %d times, want 3", calls)
+	}
+}
+
+func TestWebhookDeliverGivesUp(t *testing.T) {
+	d := &webhookDispatcher{client: http.DefaultClient}
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	if err := d.deliver(srv.URL, "motor.registered", []byte(`{}`)); err == nil {
+		t.Error("deliver succeeded against a failing receiver")
+	}
+	if calls != webhookAttempts {
+		t.Errorf("receiver called %d times, want %d", calls, webhookAttempts)
+	}
+}